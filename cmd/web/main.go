@@ -1,60 +1,142 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
+	"database/sql"
+	"errors"
 	"flag"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/YelzhanWeb/snippetbox/internal/app"
+	"github.com/YelzhanWeb/snippetbox/internal/config"
+	"github.com/YelzhanWeb/snippetbox/internal/logging"
+	"github.com/YelzhanWeb/snippetbox/internal/metrics"
 	"github.com/YelzhanWeb/snippetbox/internal/models"
+	"github.com/YelzhanWeb/snippetbox/internal/models/memory"
+	"github.com/YelzhanWeb/snippetbox/internal/ratelimit"
 	"github.com/YelzhanWeb/snippetbox/internal/server"
+	"github.com/YelzhanWeb/snippetbox/internal/sweeper"
 	storage "github.com/YelzhanWeb/snippetbox/pkg/db"
 	"github.com/alexedwards/scs/mysqlstore"
+	"github.com/alexedwards/scs/sqlite3store"
 	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
 	"github.com/go-playground/form"
 )
 
+// defaultDSNs holds the data source name used by each SQL storage backend
+// when no dsn is configured.
+var defaultDSNs = map[string]string{
+	"mysql":  "web:pass@/snippetbox?parseTime=true",
+	"sqlite": "file:snippetbox.db?_busy_timeout=5000&_journal_mode=WAL",
+}
+
+// stores groups the configured storage backend.
+type stores struct {
+	db       *sql.DB
+	snippets models.SnippetStore
+	users    models.UserStore
+	tokens   models.TokenStore
+	sessions sessionStore
+}
+
+// sessionStore is a session store which deletes expired sessions in the
+// background until StopCleanup is called.
+type sessionStore interface {
+	scs.Store
+	StopCleanup()
+}
+
 func main() {
-	addr := flag.String("addr", ":4000", "HTTP network address")
-	dsn := flag.String("dsn", "web:pass@/snippetbox?parseTime=true", "MySQL data source name")
-	flag.Parse()
+	err := run()
+	if err != nil {
+		// run makes the configured logger the default once it is known.
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+}
 
-	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
+// run loads the configuration, then starts the server and blocks until it
+// fails or is stopped by SIGINT or SIGTERM. On a signal it stops accepting
+// connections, waits up to the shutdown timeout for in-flight requests, then
+// stops the background workers. It returns nil only after a clean shutdown.
+func run() error {
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
 
-	db, err := storage.InitDB(*dsn)
+	if cfg.PrintConfig {
+		return cfg.Print(os.Stdout)
+	}
+
+	var level slog.Level
+	err = level.UnmarshalText([]byte(cfg.LogLevel))
 	if err != nil {
-		errorLog.Fatal(err)
+		return err
 	}
-	defer db.Close()
+
+	logger, err := logging.New(os.Stdout, cfg.LogFormat, level)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+
+	st, err := openStores(cfg)
+	if err != nil {
+		return err
+	}
+	if st.db != nil {
+		defer st.db.Close()
+	}
+
+	if cfg.Migrate != "" {
+		st.sessions.StopCleanup()
+		return runMigrations(st.db, cfg.Storage, cfg.Migrate, logger)
+	}
+
+	defer st.sessions.StopCleanup()
 
 	templateCache, err := models.NewTemplateCache()
 	if err != nil {
-		errorLog.Fatal(err)
+		return err
 	}
 
 	formDecoder := form.NewDecoder()
 
 	sessionManager := scs.New()
-	sessionManager.Store = mysqlstore.New(db)
-	sessionManager.Lifetime = 12 * time.Hour
-	sessionManager.Cookie.Secure = true
+	sessionManager.Store = st.sessions
+	sessionManager.Lifetime = cfg.SessionLifetime
+	sessionManager.Cookie.Secure = cfg.SessionCookieSecure
 
 	app := &app.Application{
-		ErrorLog: errorLog,
-		InfoLog:  infoLog,
-		Snippets: &models.SnippetModel{
-			DB: db,
-		},
-		Users: &models.UserModel{
-			DB: db,
-		},
+		Logger:         logger,
+		DB:             st.db,
+		Metrics:        metrics.New(st.db),
+		Snippets:       st.snippets,
+		Users:          st.users,
+		Tokens:         st.tokens,
 		TemplateCache:  templateCache,
 		FormDecoder:    formDecoder,
 		SessionManager: sessionManager,
+		SnippetUnlocks: ratelimit.New(10, 15*time.Minute),
+		ClientUnlocks:  ratelimit.New(5, time.Minute),
+		CSP:            cfg.CSP,
+		TrustedProxies: cfg.TrustedProxies,
+
+		MaxExpiry:        cfg.MaxExpiry,
+		AllowNeverExpire: cfg.AllowNeverExpire,
 	}
 
 	tlsConfig := &tls.Config{
@@ -62,18 +144,153 @@ func main() {
 	}
 
 	srv := &http.Server{
-		Addr:         *addr,
-		ErrorLog:     errorLog,
+		Addr:         cfg.Addr,
+		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
 		Handler:      server.Routes(app),
 		TLSConfig:    tlsConfig,
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  cfg.IdleTimeout,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
 	}
 
-	infoLog.Printf("Starting server on %s", *addr)
-	err = srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
+	// Background workers run until the server has stopped, and are then
+	// stopped before the deferred session cleanup and database close.
+	workers, stopWorkers := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer func() {
+		stopWorkers()
+		wg.Wait()
+	}()
+
+	if cfg.SweepInterval > 0 {
+		sw := &sweeper.Sweeper{
+			Snippets:  st.snippets,
+			Interval:  cfg.SweepInterval,
+			BatchSize: cfg.SweepBatch,
+			Logger:    logger,
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sw.Run(workers)
+		}()
+	}
+
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	serveErr := make(chan error, 2)
+	go func() {
+		logger.Info("starting server", "addr", cfg.Addr, "storage", cfg.Storage)
+		serveErr <- srv.ListenAndServeTLS(cfg.TLSCert, cfg.TLSKey)
+	}()
+
+	// The admin listener serves plain HTTP, and should only be reachable
+	// from inside the deployment.
+	var adminSrv *http.Server
+	if cfg.AdminAddr != "" {
+		adminSrv = &http.Server{
+			Addr:         cfg.AdminAddr,
+			ErrorLog:     srv.ErrorLog,
+			Handler:      server.AdminRoutes(app),
+			IdleTimeout:  cfg.IdleTimeout,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+		}
+
+		go func() {
+			logger.Info("starting admin server", "addr", cfg.AdminAddr)
+			serveErr <- adminSrv.ListenAndServe()
+		}()
+	}
+
+	select {
+	case err = <-serveErr:
+		// Whichever listener failed, stop the other one too.
+		srv.Close()
+		if adminSrv != nil {
+			adminSrv.Close()
+		}
+		return err
+	case <-signals.Done():
+	}
+
+	// Restore the default signal handling, so that a second signal stops
+	// the process straight away.
+	stopSignals()
+
+	// Fail readiness checks first, and keep serving for the drain delay so
+	// that load balancers stop sending new requests before the listener
+	// closes.
+	app.ShuttingDown.Store(true)
+	if cfg.DrainDelay > 0 {
+		logger.Info("draining, failing readiness checks", "delay", cfg.DrainDelay)
+		time.Sleep(cfg.DrainDelay)
+	}
+
+	logger.Info("shutting down, waiting for in-flight requests", "timeout", cfg.ShutdownTimeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	err = srv.Shutdown(ctx)
+	if err == nil && adminSrv != nil {
+		err = adminSrv.Shutdown(ctx)
+	}
 	if err != nil {
-		errorLog.Fatal(err)
+		srv.Close()
+		if adminSrv != nil {
+			adminSrv.Close()
+		}
+		return fmt.Errorf("shutting down: %w", err)
+	}
+
+	logger.Info("server stopped")
+	return nil
+}
+
+func openStores(cfg *config.Config) (*stores, error) {
+	dsn := cfg.DSN
+	if dsn == "" {
+		dsn = defaultDSNs[cfg.Storage]
+	}
+
+	switch cfg.Storage {
+	case "mysql":
+		db, err := storage.InitDB("mysql", dsn)
+		if err != nil {
+			return nil, err
+		}
+		return sqlStores(db, mysqlstore.New(db), cfg.BcryptCost), nil
+
+	case "sqlite":
+		db, err := storage.InitDB("sqlite3", dsn)
+		if err != nil {
+			return nil, err
+		}
+		return sqlStores(db, sqlite3store.New(db), cfg.BcryptCost), nil
+
+	case "memory":
+		users := &memory.UserModel{BcryptCost: cfg.BcryptCost}
+		return &stores{
+			snippets: &memory.SnippetModel{Users: users, BcryptCost: cfg.BcryptCost},
+			users:    users,
+			tokens:   &memory.TokenModel{},
+			sessions: memstore.New(),
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Storage)
+	}
+}
+
+func sqlStores(db *sql.DB, sessions sessionStore, bcryptCost int) *stores {
+	return &stores{
+		db:       db,
+		snippets: &models.SnippetModel{DB: db, BcryptCost: bcryptCost},
+		users:    &models.UserModel{DB: db, BcryptCost: bcryptCost},
+		tokens:   &models.TokenModel{DB: db},
+		sessions: sessions,
 	}
 }
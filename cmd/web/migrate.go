@@ -0,0 +1,65 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/YelzhanWeb/snippetbox/internal/migrate"
+)
+
+// runMigrations carries out the -migrate command against db.
+func runMigrations(db *sql.DB, dialect, command string, logger *slog.Logger) error {
+	if db == nil {
+		return fmt.Errorf("the %s storage backend has no schema to migrate", dialect)
+	}
+
+	m, err := migrate.New(db, dialect)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "up":
+		applied, err := m.Up()
+		for _, mg := range applied {
+			logger.Info("applied migration", "migration", mg.String())
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			logger.Info("the database schema is already up to date")
+		}
+
+	case "down":
+		mg, err := m.Down()
+		if errors.Is(err, migrate.ErrNoMigrations) {
+			logger.Info("there are no applied migrations to revert")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		logger.Info("reverted migration", "migration", mg.String())
+
+	case "status":
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			if s.IsApplied() {
+				fmt.Printf("%s\tapplied %s\n", s, s.Applied.Format("2006-01-02 15:04:05"))
+			} else {
+				fmt.Printf("%s\tpending\n", s)
+			}
+		}
+
+	default:
+		return fmt.Errorf("unknown migrate command %q: use up, down or status", command)
+	}
+
+	return nil
+}
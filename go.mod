@@ -2,14 +2,22 @@ module github.com/YelzhanWeb/snippetbox
 
 go 1.24.2
 
+require (
+	github.com/alecthomas/chroma/v2 v2.24.0
+	github.com/alexedwards/scs/mysqlstore v0.0.0-20250417082927-ab20b3feb5e9
+	github.com/alexedwards/scs/sqlite3store v0.0.0-20251002162104-209de6e426de
+	github.com/alexedwards/scs/v2 v2.9.0
+	github.com/go-playground/form v3.1.4+incompatible
+	github.com/go-sql-driver/mysql v1.9.3
+	github.com/julienschmidt/httprouter v1.3.0
+	github.com/justinas/alice v1.2.0
+	github.com/justinas/nosurf v1.2.0
+	github.com/mattn/go-sqlite3 v1.14.32
+	golang.org/x/crypto v0.41.0
+)
+
 require (
 	filippo.io/edwards25519 v1.1.0 // indirect
-	github.com/alexedwards/scs/mysqlstore v0.0.0-20250417082927-ab20b3feb5e9 // indirect
-	github.com/alexedwards/scs/v2 v2.9.0 // indirect
-	github.com/go-playground/form v3.1.4+incompatible // indirect
-	github.com/go-sql-driver/mysql v1.9.3 // indirect
-	github.com/julienschmidt/httprouter v1.3.0 // indirect
-	github.com/justinas/alice v1.2.0 // indirect
-	github.com/justinas/nosurf v1.2.0 // indirect
-	golang.org/x/crypto v0.41.0 // indirect
+	github.com/dlclark/regexp2 v1.12.0 // indirect
+	gopkg.in/go-playground/assert.v1 v1.2.1 // indirect
 )
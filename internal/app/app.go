@@ -1,20 +1,44 @@
 package app
 
 import (
+	"database/sql"
 	"html/template"
-	"log"
+	"log/slog"
+	"net/netip"
+	"sync/atomic"
+	"time"
 
+	"github.com/YelzhanWeb/snippetbox/internal/metrics"
 	"github.com/YelzhanWeb/snippetbox/internal/models"
+	"github.com/YelzhanWeb/snippetbox/internal/ratelimit"
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form"
 )
 
 type Application struct {
-	ErrorLog       *log.Logger
-	InfoLog        *log.Logger
-	Snippets       *models.SnippetModel
-	Users          *models.UserModel
+	Logger         *slog.Logger
+	DB             *sql.DB // nil with the memory storage backend
+	Metrics        *metrics.Metrics
+	Snippets       models.SnippetStore
+	Users          models.UserStore
+	Tokens         models.TokenStore
 	TemplateCache  map[string]*template.Template
 	FormDecoder    *form.Decoder
 	SessionManager *scs.SessionManager
+	SnippetUnlocks *ratelimit.Limiter
+	ClientUnlocks  *ratelimit.Limiter
+	CSP            string
+
+	// TrustedProxies are the reverse proxies whose X-Forwarded-For headers
+	// ClientIP believes.
+	TrustedProxies []netip.Prefix
+
+	// MaxExpiry is the longest lifetime a snippet can be given, unless
+	// AllowNeverExpire is set and it is made to never expire.
+	MaxExpiry        time.Duration
+	AllowNeverExpire bool
+
+	// ShuttingDown is set once a graceful shutdown has begun, to fail
+	// readiness checks while traffic drains.
+	ShuttingDown atomic.Bool
 }
@@ -2,4 +2,9 @@ package app
 
 type contextKey string
 
-const IsAuthenticatedContextKey = contextKey("isAuthenticated")
+const (
+	IsAuthenticatedContextKey     = contextKey("isAuthenticated")
+	AuthenticatedUserIDContextKey = contextKey("authenticatedUserID")
+	TokenScopeContextKey          = contextKey("tokenScope")
+	requestInfoContextKey         = contextKey("requestInfo")
+)
@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/netip"
 	"runtime/debug"
+	"strings"
 	"time"
 
 	"github.com/YelzhanWeb/snippetbox/internal/models"
@@ -15,10 +17,12 @@ import (
 
 func (app *Application) NewTemplateData(r *http.Request) *models.TemplData {
 	return &models.TemplData{
-		CurrentYear:     time.Now().Year(),
-		Flash:           app.SessionManager.PopString(r.Context(), "flash"),
-		IsAuthenticated: app.IsAuthenticated(r),
-		CSRFToken:       nosurf.Token(r),
+		CurrentYear:         time.Now().Year(),
+		Flash:               app.SessionManager.PopString(r.Context(), "flash"),
+		IsAuthenticated:     app.IsAuthenticated(r),
+		AuthenticatedUserID: app.AuthenticatedUserID(r),
+		CSRFToken:           nosurf.Token(r),
+		AllowNeverExpire:    app.AllowNeverExpire,
 	}
 }
 
@@ -30,13 +34,83 @@ func (app *Application) IsAuthenticated(r *http.Request) bool {
 	return isAuthenticated
 }
 
-func (app *Application) ServerError(w http.ResponseWriter, err error) {
-	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
-	app.ErrorLog.Output(2, trace)
+func (app *Application) AuthenticatedUserID(r *http.Request) int {
+	id, ok := r.Context().Value(AuthenticatedUserIDContextKey).(int)
+	if !ok {
+		return 0
+	}
+	return id
+}
+
+// CanWrite reports whether the request may modify data. Session-based
+// requests always can; token-based requests need a token with write scope.
+func (app *Application) CanWrite(r *http.Request) bool {
+	scope, ok := r.Context().Value(TokenScopeContextKey).(string)
+	if !ok {
+		return true
+	}
+	return scope == models.ScopeWrite
+}
+
+// ClientIP returns the IP address of the client which made the request. If
+// the request came through trusted proxies, that is the address the nearest
+// untrusted hop in X-Forwarded-For connected from, since entries further
+// left could have been made up by the client.
+func (app *Application) ClientIP(r *http.Request) string {
+	addr, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	ip := addr.Addr().Unmap()
+
+	if !app.trustedProxy(ip) {
+		return ip.String()
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		ip = hop.Unmap()
+		if !app.trustedProxy(ip) {
+			break
+		}
+	}
+
+	return ip.String()
+}
+
+func (app *Application) trustedProxy(ip netip.Addr) bool {
+	for _, p := range app.TrustedProxies {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (app *Application) ServerError(w http.ResponseWriter, r *http.Request, err error) {
+	app.logError(r, err)
 
 	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 }
 
+// logError logs an unexpected error with the request it occurred in and a
+// stack trace.
+func (app *Application) logError(r *http.Request, err error) {
+	app.Logger.ErrorContext(r.Context(), err.Error(),
+		"method", r.Method,
+		"uri", r.URL.RequestURI(),
+		"trace", string(debug.Stack()),
+	)
+}
+
 func (app *Application) ClientError(w http.ResponseWriter, status int) {
 	http.Error(w, http.StatusText(status), status)
 }
@@ -45,19 +119,21 @@ func (app *Application) NotFound(w http.ResponseWriter) {
 	app.ClientError(w, http.StatusNotFound)
 }
 
-func (app *Application) Render(w http.ResponseWriter, status int, page string, data *models.TemplData) {
+func (app *Application) Render(w http.ResponseWriter, r *http.Request, status int, page string, data *models.TemplData) {
 	ts, ok := app.TemplateCache[page]
 	if !ok {
 		err := fmt.Errorf("the template %s does not exist", page)
-		app.ServerError(w, err)
+		app.ServerError(w, r, err)
 		return
 	}
 
 	buf := new(bytes.Buffer)
 
+	start := time.Now()
 	err := ts.ExecuteTemplate(buf, "base", data)
+	app.Metrics.RenderDuration.With(page).Observe(time.Since(start).Seconds())
 	if err != nil {
-		app.ServerError(w, err)
+		app.ServerError(w, r, err)
 		return
 	}
 	w.WriteHeader(status)
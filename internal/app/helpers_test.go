@@ -0,0 +1,48 @@
+package app
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	app := &Application{
+		TrustedProxies: []netip.Prefix{
+			netip.MustParsePrefix("10.0.0.0/8"),
+			netip.MustParsePrefix("192.0.2.1/32"),
+		},
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{"direct", "203.0.113.7:5555", nil, "203.0.113.7"},
+		{"untrusted peer's header ignored", "203.0.113.7:5555", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"trusted proxy", "10.1.2.3:5555", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"spoofed entries left of the client", "10.1.2.3:5555", []string{"1.1.1.1, 198.51.100.1"}, "198.51.100.1"},
+		{"chain of trusted proxies", "10.1.2.3:5555", []string{"198.51.100.1, 192.0.2.1", "10.9.9.9"}, "198.51.100.1"},
+		{"only trusted hops", "10.1.2.3:5555", []string{"10.4.4.4"}, "10.4.4.4"},
+		{"trusted proxy without header", "10.1.2.3:5555", nil, "10.1.2.3"},
+		{"malformed hop", "10.1.2.3:5555", []string{"198.51.100.1, junk"}, "10.1.2.3"},
+		{"IPv6", "[2001:db8::1]:5555", nil, "2001:db8::1"},
+		{"IPv4-mapped IPv6 proxy", "[::ffff:10.1.2.3]:5555", []string{"198.51.100.1"}, "198.51.100.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+
+			if got := app.ClientIP(r); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/YelzhanWeb/snippetbox/internal/validator"
+)
+
+var ErrUnsupportedMediaType = errors.New("Content-Type header must be application/json")
+
+// Envelope wraps every JSON response body so that clients can tell a
+// successful payload apart from an error by its top-level key.
+type Envelope map[string]any
+
+// IsAPIRequest reports whether r is addressed to the JSON API, and so should
+// get JSON rather than HTML error responses.
+func IsAPIRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/")
+}
+
+func (app *Application) WriteJSON(w http.ResponseWriter, status int, data Envelope, headers http.Header) {
+	// Envelopes only hold values built by the handlers, so failing to
+	// encode one is a bug, reported by RecoverPanic like any other.
+	js, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		panic(err)
+	}
+
+	for key, value := range headers {
+		w.Header()[key] = value
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(js, '\n'))
+}
+
+// ReadJSON decodes a single JSON object from the request body into dst.
+// Requests must declare an application/json body, which also stops browsers
+// from sending them cross-site without a CORS preflight.
+func (app *Application) ReadJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		return ErrUnsupportedMediaType
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1_048_576)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err != nil {
+		var syntaxError *json.SyntaxError
+		var unmarshalTypeError *json.UnmarshalTypeError
+		var maxBytesError *http.MaxBytesError
+		var invalidUnmarshalError *json.InvalidUnmarshalError
+
+		switch {
+		case errors.As(err, &syntaxError):
+			return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return errors.New("body contains badly-formed JSON")
+		case errors.As(err, &unmarshalTypeError):
+			if unmarshalTypeError.Field != "" {
+				return fmt.Errorf("body contains incorrect JSON type for field %q", unmarshalTypeError.Field)
+			}
+			return fmt.Errorf("body contains incorrect JSON type (at character %d)", unmarshalTypeError.Offset)
+		case errors.Is(err, io.EOF):
+			return errors.New("body must not be empty")
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			fieldName := strings.TrimPrefix(err.Error(), "json: unknown field ")
+			return fmt.Errorf("body contains unknown key %s", fieldName)
+		case errors.As(err, &maxBytesError):
+			return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+		case errors.As(err, &invalidUnmarshalError):
+			panic(err)
+		default:
+			return err
+		}
+	}
+
+	err = dec.Decode(&struct{}{})
+	if !errors.Is(err, io.EOF) {
+		return errors.New("body must only contain a single JSON value")
+	}
+
+	return nil
+}
+
+func (app *Application) errorJSON(w http.ResponseWriter, status int, message any) {
+	app.WriteJSON(w, status, Envelope{"error": message}, nil)
+}
+
+func (app *Application) ServerErrorJSON(w http.ResponseWriter, r *http.Request, err error) {
+	app.logError(r, err)
+
+	app.errorJSON(w, http.StatusInternalServerError, Envelope{
+		"message": http.StatusText(http.StatusInternalServerError),
+	})
+}
+
+func (app *Application) ClientErrorJSON(w http.ResponseWriter, status int) {
+	app.errorJSON(w, status, Envelope{"message": http.StatusText(status)})
+}
+
+// ClientErrorMessageJSON reports a client error with a message explaining
+// it, in place of the status text.
+func (app *Application) ClientErrorMessageJSON(w http.ResponseWriter, status int, message string) {
+	app.errorJSON(w, status, Envelope{"message": message})
+}
+
+func (app *Application) NotFoundJSON(w http.ResponseWriter) {
+	app.ClientErrorJSON(w, http.StatusNotFound)
+}
+
+// BadRequestJSON reports a request body that could not be read.
+func (app *Application) BadRequestJSON(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, ErrUnsupportedMediaType) {
+		status = http.StatusUnsupportedMediaType
+	}
+
+	app.errorJSON(w, status, Envelope{"message": err.Error()})
+}
+
+// FailedValidationJSON reports the field and non-field errors collected by
+// a validator.Validator.
+func (app *Application) FailedValidationJSON(w http.ResponseWriter, v validator.Validator) {
+	fieldErrors := v.FieldErrors
+	if fieldErrors == nil {
+		fieldErrors = map[string]string{}
+	}
+
+	nonFieldErrors := v.NonFieldErrors
+	if nonFieldErrors == nil {
+		nonFieldErrors = []string{}
+	}
+
+	app.errorJSON(w, http.StatusUnprocessableEntity, Envelope{
+		"message":          "the request contains invalid fields",
+		"field_errors":     fieldErrors,
+		"non_field_errors": nonFieldErrors,
+	})
+}
@@ -2,9 +2,16 @@ package app
 
 import (
 	"context"
+	"crypto/rand"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/YelzhanWeb/snippetbox/internal/logging"
+	"github.com/YelzhanWeb/snippetbox/internal/models"
 	"github.com/justinas/nosurf"
 )
 
@@ -22,8 +29,98 @@ func (app *Application) RequireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
+// RequireAuthenticationJSON is the API counterpart of RequireAuthentication.
+// Unauthenticated requests get a 401 response rather than a redirect.
+func (app *Application) RequireAuthenticationJSON(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.IsAuthenticated(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			app.ClientErrorJSON(w, http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Add("Cache-Control", "no-store")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireWriteAccessJSON rejects requests which aren't authenticated with a
+// personal access token with the write scope. The API has no CSRF
+// protection, so a session cookie alone isn't enough to change anything.
+func (app *Application) RequireWriteAccessJSON(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(TokenScopeContextKey).(string); !ok {
+			app.errorJSON(w, http.StatusForbidden, Envelope{
+				"message": "changes through the API need a personal access token in the Authorization header",
+			})
+			return
+		}
+
+		if !app.CanWrite(r) {
+			app.errorJSON(w, http.StatusForbidden, Envelope{
+				"message": "this token does not have the write scope",
+			})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AuthenticateToken authenticates requests carrying an
+// "Authorization: Bearer <token>" header using a personal access token.
+// Requests without the header are passed on unchanged.
+func (app *Application) AuthenticateToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Authorization")
+
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		plaintext, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || plaintext == "" {
+			app.invalidTokenJSON(w)
+			return
+		}
+
+		token, err := app.Tokens.Authenticate(plaintext)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.invalidTokenJSON(w)
+			} else {
+				app.ServerErrorJSON(w, r, err)
+			}
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), IsAuthenticatedContextKey, true)
+		ctx = context.WithValue(ctx, AuthenticatedUserIDContextKey, token.UserID)
+		ctx = context.WithValue(ctx, TokenScopeContextKey, token.Scope)
+		r = r.WithContext(ctx)
+		logUser(r, token.UserID)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *Application) invalidTokenJSON(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	app.errorJSON(w, http.StatusUnauthorized, Envelope{
+		"message": "invalid or expired authentication token",
+	})
+}
+
 func (app *Application) Authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.IsAuthenticated(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		id := app.SessionManager.GetInt(r.Context(), "authenticatedUserID")
 		if id == 0 {
 			next.ServeHTTP(w, r)
@@ -32,33 +129,46 @@ func (app *Application) Authenticate(next http.Handler) http.Handler {
 
 		exists, err := app.Users.Exists(id)
 		if err != nil {
-			app.ServerError(w, err)
+			app.ServerError(w, r, err)
 			return
 		}
 
 		if exists {
 			ctx := context.WithValue(r.Context(), IsAuthenticatedContextKey, true)
+			ctx = context.WithValue(ctx, AuthenticatedUserIDContextKey, id)
 			r = r.WithContext(ctx)
+			logUser(r, id)
 		}
 
 		next.ServeHTTP(w, r)
 	})
 }
 
-func NoSurf(next http.Handler) http.Handler {
+// NoSurf protects against CSRF. Its cookie is only sent over HTTPS if the
+// session cookie is, so that both work when serving plain HTTP in
+// development.
+func (app *Application) NoSurf(next http.Handler) http.Handler {
+	secure := app.SessionManager.Cookie.Secure
+
 	csrfHandler := nosurf.New(next)
 	csrfHandler.SetBaseCookie(http.Cookie{
 		HttpOnly: true,
 		Path:     "/",
-		Secure:   true,
+		Secure:   secure,
 	})
+
+	// nosurf assumes HTTPS when checking that requests come from the same
+	// origin, which can't be true of plain HTTP requests.
+	if !secure {
+		csrfHandler.SetIsTLSFunc(func(r *http.Request) bool { return r.TLS != nil })
+	}
+
 	return csrfHandler
 }
 
-func SecureHeaders(next http.Handler) http.Handler {
+func (app *Application) SecureHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Security-Policy",
-			"default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com")
+		w.Header().Set("Content-Security-Policy", app.CSP)
 		w.Header().Set("Referrer-Policy", "origin-when-cross-origin")
 		w.Header().Set("X-Content-Type-Options", "nosniff")
 		w.Header().Set("X-Frame-Options", "deny")
@@ -67,20 +177,184 @@ func SecureHeaders(next http.Handler) http.Handler {
 	})
 }
 
+// RequestID gives every request an ID, taken from its X-Request-ID header
+// if it has a usable one, which is returned in the response and tags every
+// record logged with the request's context.
+func (app *Application) RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = rand.Text()
+		}
+
+		w.Header().Set("X-Request-ID", id)
+
+		r = r.WithContext(logging.WithRequestID(r.Context(), id))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validRequestID reports whether a client-supplied request ID is short and
+// plain enough to copy into logs and responses.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range []byte(id) {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("-_.:", c) >= 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// LogRequest logs each request once it has been handled, with the status,
+// size and duration of the response and the ID of the authenticated user.
 func (app *Application) LogRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		app.InfoLog.Printf("%s - %s %s %s", r.RemoteAddr, r.Proto, r.Method, r.URL.RequestURI())
+		start := time.Now()
+
+		rec := &responseRecorder{ResponseWriter: w}
+		info, r := withRequestInfo(r)
+
+		next.ServeHTTP(rec, r)
+
+		app.Logger.InfoContext(r.Context(), "request",
+			"remote_addr", r.RemoteAddr,
+			"proto", r.Proto,
+			"method", r.Method,
+			"uri", r.URL.RequestURI(),
+			"status", rec.Status(),
+			"bytes", rec.bytes,
+			"duration", time.Since(start),
+			"user_id", info.userID,
+		)
+	})
+}
+
+// RecordMetrics counts and times each request by its method, route pattern
+// and response status.
+func (app *Application) RecordMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		rec := &responseRecorder{ResponseWriter: w}
+		info, r := withRequestInfo(r)
+
+		next.ServeHTTP(rec, r)
+
+		route := info.route
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(rec.Status())
+
+		method := metricMethod(r.Method)
+
+		app.Metrics.Requests.With(method, route, status).Inc()
+		app.Metrics.RequestDuration.With(method, route, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// metricMethod returns the method label for a request. Clients can send any
+// method, so unknown ones share a label rather than each creating series.
+func metricMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodDelete, http.MethodPatch, http.MethodOptions:
+		return method
+	default:
+		return "other"
+	}
+}
+
+// WithRoute records the route pattern next is registered under, for
+// RecordMetrics to label requests with rather than their raw paths.
+func WithRoute(pattern string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if info, ok := r.Context().Value(requestInfoContextKey).(*requestInfo); ok {
+			info.route = pattern
+		}
 		next.ServeHTTP(w, r)
 	})
 }
 
+// requestInfo collects details for LogRequest and RecordMetrics which are
+// only known to inner handlers, as the requests they see carry contexts
+// derived from the outer ones.
+type requestInfo struct {
+	userID int
+	route  string
+}
+
+// withRequestInfo returns the requestInfo carried by r, adding one to its
+// context if it has none yet.
+func withRequestInfo(r *http.Request) (*requestInfo, *http.Request) {
+	if info, ok := r.Context().Value(requestInfoContextKey).(*requestInfo); ok {
+		return info, r
+	}
+
+	info := &requestInfo{}
+	return info, r.WithContext(context.WithValue(r.Context(), requestInfoContextKey, info))
+}
+
+// logUser records the ID of the user a request was authenticated as.
+func logUser(r *http.Request, id int) {
+	if info, ok := r.Context().Value(requestInfoContextKey).(*requestInfo); ok {
+		info.userID = id
+	}
+}
+
+// responseRecorder records the status code and size of a response. Unwrap
+// lets http.ResponseController reach the underlying writer's Flush and
+// deadline methods.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 && status >= 200 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// Status returns the status code sent, which is 200 if the handler wrote
+// nothing at all.
+func (rec *responseRecorder) Status() int {
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+	return rec.status
+}
+
 func (app *Application) RecoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
 				w.Header().Set("Connection", "close")
+				app.Metrics.Panics.Inc()
 
-				app.ServerError(w, fmt.Errorf("%s", err))
+				if IsAPIRequest(r) {
+					app.ServerErrorJSON(w, r, fmt.Errorf("%s", err))
+					return
+				}
+				app.ServerError(w, r, fmt.Errorf("%s", err))
 			}
 		}()
 
@@ -0,0 +1,411 @@
+// Package config loads the server's settings. Each setting can be given in a
+// JSON config file, in a SNIPPETBOX_* environment variable, or as a
+// command-line flag, in increasing order of precedence.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/YelzhanWeb/snippetbox/internal/logging"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Config holds the effective settings.
+type Config struct {
+	Addr      string
+	AdminAddr string
+	Storage   string
+	DSN       string
+
+	// TrustedProxies are the addresses of reverse proxies and load
+	// balancers whose X-Forwarded-For headers are believed.
+	TrustedProxies []netip.Prefix
+
+	TLSCert string
+	TLSKey  string
+
+	SessionLifetime     time.Duration
+	SessionCookieSecure bool
+	BcryptCost          int
+	CSP                 string
+
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+	DrainDelay      time.Duration
+
+	MaxExpiry        time.Duration
+	AllowNeverExpire bool
+	SweepInterval    time.Duration
+	SweepBatch       int
+
+	LogFormat string
+	LogLevel  string
+
+	// Migrate and PrintConfig are commands rather than settings, and can
+	// only be given as flags.
+	Migrate     string
+	PrintConfig bool
+}
+
+// Default returns the settings used when nothing else is given.
+func Default() *Config {
+	return &Config{
+		Addr:    ":4000",
+		Storage: "mysql",
+
+		TLSCert: "./tls/cert.pem",
+		TLSKey:  "./tls/key.pem",
+
+		SessionLifetime:     12 * time.Hour,
+		SessionCookieSecure: true,
+		BcryptCost:          12,
+		CSP:                 "default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com",
+
+		ReadTimeout:     5 * time.Second,
+		WriteTimeout:    10 * time.Second,
+		IdleTimeout:     time.Minute,
+		ShutdownTimeout: 20 * time.Second,
+		DrainDelay:      5 * time.Second,
+
+		MaxExpiry:        365 * 24 * time.Hour,
+		AllowNeverExpire: true,
+		SweepInterval:    10 * time.Minute,
+		SweepBatch:       500,
+
+		LogFormat: "text",
+		LogLevel:  "info",
+	}
+}
+
+// setting describes one configurable field. Its name is used as the flag
+// name and the key in config files, and gives the environment variable
+// name: "read-timeout" is set by SNIPPETBOX_READ_TIMEOUT.
+type setting struct {
+	name     string
+	usage    string
+	value    func(c *Config) flag.Value
+	secret   bool
+	flagOnly bool
+}
+
+var settings = []setting{
+	{name: "addr", usage: "HTTP network address", value: func(c *Config) flag.Value { return (*stringValue)(&c.Addr) }},
+	{name: "admin-addr", usage: "Network address serving plain HTTP /metrics and /readyz (empty disables)", value: func(c *Config) flag.Value { return (*stringValue)(&c.AdminAddr) }},
+	{name: "trusted-proxies", usage: "Comma-separated IPs or CIDR ranges of proxies whose X-Forwarded-For header is trusted", value: func(c *Config) flag.Value { return (*prefixesValue)(&c.TrustedProxies) }},
+	{name: "storage", usage: "Storage backend: mysql, sqlite or memory", value: func(c *Config) flag.Value { return (*stringValue)(&c.Storage) }},
+	{name: "dsn", usage: "Data source name for the mysql or sqlite storage backend", value: func(c *Config) flag.Value { return (*stringValue)(&c.DSN) }, secret: true},
+	{name: "tls-cert", usage: "TLS certificate file", value: func(c *Config) flag.Value { return (*stringValue)(&c.TLSCert) }},
+	{name: "tls-key", usage: "TLS private key file", value: func(c *Config) flag.Value { return (*stringValue)(&c.TLSKey) }},
+	{name: "session-lifetime", usage: "How long a session lasts", value: func(c *Config) flag.Value { return (*durationValue)(&c.SessionLifetime) }},
+	{name: "session-cookie-secure", usage: "Only send the session and CSRF cookies over HTTPS", value: func(c *Config) flag.Value { return (*boolValue)(&c.SessionCookieSecure) }},
+	{name: "bcrypt-cost", usage: "bcrypt cost used to hash passwords", value: func(c *Config) flag.Value { return (*intValue)(&c.BcryptCost) }},
+	{name: "csp", usage: "Content-Security-Policy header sent with every response", value: func(c *Config) flag.Value { return (*stringValue)(&c.CSP) }},
+	{name: "read-timeout", usage: "Maximum time to read a request", value: func(c *Config) flag.Value { return (*durationValue)(&c.ReadTimeout) }},
+	{name: "write-timeout", usage: "Maximum time to write a response", value: func(c *Config) flag.Value { return (*durationValue)(&c.WriteTimeout) }},
+	{name: "idle-timeout", usage: "How long to keep idle connections open", value: func(c *Config) flag.Value { return (*durationValue)(&c.IdleTimeout) }},
+	{name: "shutdown-timeout", usage: "How long to wait for in-flight requests when shutting down", value: func(c *Config) flag.Value { return (*durationValue)(&c.ShutdownTimeout) }},
+	{name: "drain-delay", usage: "How long to keep serving while failing /readyz before shutting down", value: func(c *Config) flag.Value { return (*durationValue)(&c.DrainDelay) }},
+	{name: "max-expiry", usage: "Longest lifetime a snippet can be given", value: func(c *Config) flag.Value { return (*durationValue)(&c.MaxExpiry) }},
+	{name: "allow-never-expire", usage: "Allow snippets which never expire", value: func(c *Config) flag.Value { return (*boolValue)(&c.AllowNeverExpire) }},
+	{name: "sweep-interval", usage: "How often to delete expired snippets (0 disables)", value: func(c *Config) flag.Value { return (*durationValue)(&c.SweepInterval) }},
+	{name: "sweep-batch", usage: "Most expired snippets to delete in one transaction", value: func(c *Config) flag.Value { return (*intValue)(&c.SweepBatch) }},
+	{name: "log-format", usage: "Log format: text or json", value: func(c *Config) flag.Value { return (*stringValue)(&c.LogFormat) }},
+	{name: "log-level", usage: "Least severe level to log: debug, info, warn or error", value: func(c *Config) flag.Value { return (*stringValue)(&c.LogLevel) }},
+	{name: "migrate", usage: "Run schema migrations (up, down or status) and exit", value: func(c *Config) flag.Value { return (*stringValue)(&c.Migrate) }, flagOnly: true},
+	{name: "print-config", usage: "Print the effective configuration, with secrets redacted, and exit", value: func(c *Config) flag.Value { return (*boolValue)(&c.PrintConfig) }, flagOnly: true},
+}
+
+// envPrefix prefixes the names of environment variables read by Load.
+const envPrefix = "SNIPPETBOX_"
+
+func envName(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// Load builds the configuration from the command-line arguments, the
+// environment and the config file named by the -config flag or the
+// SNIPPETBOX_CONFIG variable, then validates it.
+func Load(args []string, getenv func(string) string) (*Config, error) {
+	// Flags are parsed into a separate Config, and only those which were
+	// actually given are copied over the file and environment settings.
+	flags := Default()
+
+	fs := flag.NewFlagSet("web", flag.ContinueOnError)
+	configFile := fs.String("config", getenv(envPrefix+"CONFIG"), "JSON config file")
+	for _, s := range settings {
+		fs.Var(s.value(flags), s.name, s.usage)
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	cfg := Default()
+
+	if *configFile != "" {
+		err = cfg.loadFile(*configFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range settings {
+		if s.flagOnly {
+			continue
+		}
+		if v := getenv(envName(s.name)); v != "" {
+			err = s.value(cfg).Set(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid value %q: %w", envName(s.name), v, err)
+			}
+		}
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name != "config" {
+			settingByName(f.Name).value(cfg).Set(f.Value.String())
+		}
+	})
+
+	err = cfg.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func settingByName(name string) *setting {
+	for i := range settings {
+		if settings[i].name == name {
+			return &settings[i]
+		}
+	}
+	return nil
+}
+
+// loadFile applies the settings in a JSON config file: an object whose keys
+// are setting names. Durations are given as strings such as "12h".
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	var values map[string]json.RawMessage
+	err = json.Unmarshal(data, &values)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	for name, raw := range values {
+		s := settingByName(name)
+		if s == nil || s.flagOnly {
+			return fmt.Errorf("%s: unknown setting %q", path, name)
+		}
+
+		// Strings are unquoted; numbers and booleans are used as written.
+		text := string(bytes.TrimSpace(raw))
+		var str string
+		if json.Unmarshal(raw, &str) == nil {
+			text = str
+		}
+
+		err = s.value(c).Set(text)
+		if err != nil {
+			return fmt.Errorf("%s: invalid value for %q: %w", path, name, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Addr != "", "addr must not be empty")
+	check(c.AdminAddr == "" || c.AdminAddr != c.Addr, "admin-addr must differ from addr")
+	check(slices.Contains([]string{"mysql", "sqlite", "memory"}, c.Storage), "storage must be mysql, sqlite or memory, not %q", c.Storage)
+	check(c.TLSCert != "" && c.TLSKey != "", "tls-cert and tls-key must not be empty")
+	check(c.SessionLifetime > 0, "session-lifetime must be positive")
+	check(c.BcryptCost >= bcrypt.MinCost && c.BcryptCost <= bcrypt.MaxCost, "bcrypt-cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	check(c.CSP != "", "csp must not be empty")
+	check(c.ReadTimeout > 0 && c.WriteTimeout > 0 && c.IdleTimeout > 0, "read-timeout, write-timeout and idle-timeout must be positive")
+	check(c.ShutdownTimeout > 0, "shutdown-timeout must be positive")
+	check(c.DrainDelay >= 0, "drain-delay must not be negative")
+	check(c.MaxExpiry >= time.Minute, "max-expiry must be at least 1m")
+	check(c.SweepInterval >= 0, "sweep-interval must not be negative")
+	check(c.SweepBatch >= 1, "sweep-batch must be at least 1")
+	check(slices.Contains(logging.Formats, c.LogFormat), "log-format must be text or json, not %q", c.LogFormat)
+	check(new(slog.Level).UnmarshalText([]byte(c.LogLevel)) == nil, "log-level must be debug, info, warn or error, not %q", c.LogLevel)
+	check(slices.Contains([]string{"", "up", "down", "status"}, c.Migrate), "migrate must be up, down or status, not %q", c.Migrate)
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// Print writes the settings as a JSON config file, which can be loaded
+// again with -config. Secrets are redacted.
+func (c *Config) Print(w io.Writer) error {
+	var buf bytes.Buffer
+	buf.WriteString("{\n")
+
+	first := true
+	for _, s := range settings {
+		if s.flagOnly {
+			continue
+		}
+
+		value := s.value(c).String()
+		if s.secret {
+			value = redact(value)
+		}
+
+		var js []byte
+		switch s.value(c).(type) {
+		case *intValue, *boolValue:
+			js = []byte(value)
+		default:
+			js, _ = json.Marshal(value)
+		}
+
+		if !first {
+			buf.WriteString(",\n")
+		}
+		first = false
+		fmt.Fprintf(&buf, "\t%q: %s", s.name, js)
+	}
+
+	buf.WriteString("\n}\n")
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// redact hides the password in a data source name such as
+// "user:password@tcp(host)/db".
+func redact(dsn string) string {
+	at := strings.LastIndex(dsn, "@")
+	if at < 0 {
+		return dsn
+	}
+
+	colon := strings.Index(dsn[:at], ":")
+	if colon < 0 {
+		return dsn
+	}
+
+	return dsn[:colon+1] + "REDACTED" + dsn[at:]
+}
+
+type stringValue string
+
+func (v *stringValue) Set(s string) error { *v = stringValue(s); return nil }
+func (v *stringValue) String() string     { return string(*v) }
+
+type intValue int
+
+func (v *intValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return errors.New("must be a whole number")
+	}
+	*v = intValue(n)
+	return nil
+}
+
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+
+type boolValue bool
+
+func (v *boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return errors.New("must be true or false")
+	}
+	*v = boolValue(b)
+	return nil
+}
+
+func (v *boolValue) String() string   { return strconv.FormatBool(bool(*v)) }
+func (v *boolValue) IsBoolFlag() bool { return true }
+
+type durationValue time.Duration
+
+func (v *durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return errors.New("must be a duration such as 30s, 5m or 12h")
+	}
+	*v = durationValue(d)
+	return nil
+}
+
+func (v *durationValue) String() string { return time.Duration(*v).String() }
+
+type prefixesValue []netip.Prefix
+
+func (v *prefixesValue) Set(s string) error {
+	var prefixes []netip.Prefix
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		if !strings.Contains(field, "/") {
+			addr, err := netip.ParseAddr(field)
+			if err != nil {
+				return fmt.Errorf("%q is not an IP address or CIDR range", field)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(field)
+		if err != nil {
+			return fmt.Errorf("%q is not an IP address or CIDR range", field)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	*v = prefixes
+	return nil
+}
+
+func (v *prefixesValue) String() string {
+	if v == nil {
+		return ""
+	}
+
+	fields := make([]string, len(*v))
+	for i, p := range *v {
+		fields[i] = p.String()
+	}
+	return strings.Join(fields, ",")
+}
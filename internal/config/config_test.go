@@ -0,0 +1,256 @@
+package config
+
+import (
+	"bytes"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile writes a config file to a temporary directory and returns its
+// path.
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(path, []byte(content), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func environment(env map[string]string) func(string) string {
+	return func(name string) string { return env[name] }
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := writeFile(t, `{
+		"addr": ":5000",
+		"bcrypt-cost": 10,
+		"session-cookie-secure": false,
+		"read-timeout": "7s",
+		"trusted-proxies": "10.0.0.0/8"
+	}`)
+	other := writeFile(t, `{"addr": ":5500"}`)
+
+	tests := []struct {
+		name   string
+		args   []string
+		env    map[string]string
+		modify func(c *Config)
+	}{
+		{
+			name:   "defaults",
+			modify: func(c *Config) {},
+		},
+		{
+			name: "file",
+			args: []string{"-config", file},
+			modify: func(c *Config) {
+				c.Addr = ":5000"
+				c.BcryptCost = 10
+				c.SessionCookieSecure = false
+				c.ReadTimeout = 7 * time.Second
+				c.TrustedProxies = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+			},
+		},
+		{
+			name: "file named by the environment",
+			env:  map[string]string{"SNIPPETBOX_CONFIG": other},
+			modify: func(c *Config) {
+				c.Addr = ":5500"
+			},
+		},
+		{
+			name: "config flag over the environment",
+			args: []string{"-config", other},
+			env:  map[string]string{"SNIPPETBOX_CONFIG": file},
+			modify: func(c *Config) {
+				c.Addr = ":5500"
+			},
+		},
+		{
+			name: "environment over file",
+			args: []string{"-config", file},
+			env: map[string]string{
+				"SNIPPETBOX_ADDR":                  ":6000",
+				"SNIPPETBOX_SESSION_COOKIE_SECURE": "true",
+				"SNIPPETBOX_TRUSTED_PROXIES":       "192.0.2.1, 2001:db8::/32",
+			},
+			modify: func(c *Config) {
+				c.Addr = ":6000"
+				c.BcryptCost = 10
+				c.ReadTimeout = 7 * time.Second
+				c.TrustedProxies = []netip.Prefix{netip.MustParsePrefix("192.0.2.1/32"), netip.MustParsePrefix("2001:db8::/32")}
+			},
+		},
+		{
+			name: "flags over environment",
+			args: []string{"-config", file, "-addr", ":7000", "-read-timeout", "9s"},
+			env:  map[string]string{"SNIPPETBOX_ADDR": ":6000", "SNIPPETBOX_READ_TIMEOUT": "8s"},
+			modify: func(c *Config) {
+				c.Addr = ":7000"
+				c.BcryptCost = 10
+				c.SessionCookieSecure = false
+				c.ReadTimeout = 9 * time.Second
+				c.TrustedProxies = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+			},
+		},
+		{
+			name: "flag given its default value",
+			args: []string{"-addr", ":4000"},
+			env:  map[string]string{"SNIPPETBOX_ADDR": ":6000"},
+			modify: func(c *Config) {
+				c.Addr = ":4000"
+			},
+		},
+		{
+			name: "boolean flag over environment",
+			args: []string{"-session-cookie-secure"},
+			env:  map[string]string{"SNIPPETBOX_SESSION_COOKIE_SECURE": "false"},
+			modify: func(c *Config) {
+				c.SessionCookieSecure = true
+			},
+		},
+		{
+			name:   "empty variables are ignored",
+			env:    map[string]string{"SNIPPETBOX_ADDR": "", "SNIPPETBOX_CONFIG": ""},
+			modify: func(c *Config) {},
+		},
+		{
+			name: "commands are only flags",
+			args: []string{"-migrate", "status"},
+			env:  map[string]string{"SNIPPETBOX_MIGRATE": "up", "SNIPPETBOX_PRINT_CONFIG": "true"},
+			modify: func(c *Config) {
+				c.Migrate = "status"
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Load(tt.args, environment(tt.env))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := Default()
+			tt.modify(want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got:\n%+v\nwant:\n%+v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		file string
+		want string
+	}{
+		{name: "unknown flag", args: []string{"-colour"}, want: "flag provided but not defined"},
+		{name: "invalid flag", args: []string{"-bcrypt-cost", "high"}, want: "invalid value"},
+		{name: "extra arguments", args: []string{"serve"}, want: "unexpected arguments: serve"},
+		{name: "invalid variable", env: map[string]string{"SNIPPETBOX_READ_TIMEOUT": "soon"}, want: "SNIPPETBOX_READ_TIMEOUT: invalid value"},
+		{name: "missing file", args: []string{"-config", "/nonexistent/config.json"}, want: "reading config file"},
+		{name: "malformed file", file: `{"addr": `, want: "unexpected end of JSON input"},
+		{name: "unknown setting in file", file: `{"colour": "blue"}`, want: `unknown setting "colour"`},
+		{name: "command in file", file: `{"migrate": "up"}`, want: `unknown setting "migrate"`},
+		{name: "invalid value in file", file: `{"bcrypt-cost": "high"}`, want: `invalid value for "bcrypt-cost"`},
+		{name: "invalid configuration", args: []string{"-storage", "postgres", "-bcrypt-cost", "99"}, want: "storage must be mysql, sqlite or memory"},
+		{name: "every problem is reported", args: []string{"-storage", "postgres", "-bcrypt-cost", "99"}, want: "bcrypt-cost must be between"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeFile(t, tt.file)}, args...)
+			}
+
+			_, err := Load(args, environment(tt.env))
+			if err == nil {
+				t.Fatalf("got nil error; want one containing %q", tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %q; want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintRoundTrip(t *testing.T) {
+	cfg, err := Load([]string{
+		"-storage", "sqlite",
+		"-dsn", "file:test.db",
+		"-csp", `default-src 'self'; img-src "data:"`,
+		"-trusted-proxies", "10.0.0.0/8,192.0.2.1",
+		"-sweep-batch", "50",
+		"-allow-never-expire=false",
+	}, environment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	err = cfg.Print(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load([]string{"-config", writeFile(t, buf.String())}, environment(nil))
+	if err != nil {
+		t.Fatalf("loading the printed config: %v\n%s", err, buf.String())
+	}
+	if !reflect.DeepEqual(loaded, cfg) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", loaded, cfg)
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.DSN = "web:pa55word@tcp(db:3306)/snippetbox?parseTime=true"
+
+	var buf bytes.Buffer
+	err := cfg.Print(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(buf.String(), "pa55word") {
+		t.Errorf("printed config contains the password:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `"dsn": "web:REDACTED@tcp(db:3306)/snippetbox?parseTime=true"`) {
+		t.Errorf("printed config doesn't contain the redacted DSN:\n%s", buf.String())
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		want string
+	}{
+		{"web:pass@tcp(localhost:3306)/snippetbox", "web:REDACTED@tcp(localhost:3306)/snippetbox"},
+		{"web:p@ss:w@rd@/snippetbox", "web:REDACTED@/snippetbox"},
+		{"web:@tcp(localhost)/snippetbox", "web:REDACTED@tcp(localhost)/snippetbox"},
+		{"web@tcp(localhost:3306)/snippetbox", "web@tcp(localhost:3306)/snippetbox"},
+		{"file:snippetbox.db?_busy_timeout=5000", "file:snippetbox.db?_busy_timeout=5000"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dsn, func(t *testing.T) {
+			if got := redact(tt.dsn); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,311 @@
+// Package detect guesses the language of a snippet from its title and
+// content. It only uses fixed heuristics, so the same input always produces
+// the same guess.
+package detect
+
+import (
+	"encoding/json"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/YelzhanWeb/snippetbox/internal/highlight"
+)
+
+// sampleSize is the number of bytes of content examined by the keyword
+// heuristics.
+const sampleSize = 16 * 1024
+
+// minScore is the keyword score a language needs before it is guessed.
+const minScore = 4
+
+// maxMatches caps how many times a single pattern counts towards a score, so
+// that one common construct can't outweigh several distinctive ones.
+const maxMatches = 5
+
+// filenames maps whole file names, as they might appear in a title, to
+// languages.
+var filenames = map[string]string{
+	"dockerfile":    "dockerfile",
+	"containerfile": "dockerfile",
+	"nginx.conf":    "nginx",
+	".bashrc":       "bash",
+	".zshrc":        "bash",
+	".profile":      "bash",
+}
+
+// extensions maps file name extensions to languages.
+var extensions = map[string]string{
+	".go":   "go",
+	".sql":  "sql",
+	".yaml": "yaml",
+	".yml":  "yaml",
+	".json": "json",
+	".sh":   "bash",
+	".bash": "bash",
+	".zsh":  "bash",
+	".py":   "python",
+	".js":   "javascript",
+	".mjs":  "javascript",
+	".cjs":  "javascript",
+	".ts":   "typescript",
+	".tsx":  "typescript",
+	".rs":   "rust",
+	".java": "java",
+	".c":    "c",
+	".h":    "c",
+	".css":  "css",
+	".html": "html",
+	".htm":  "html",
+	".md":   "markdown",
+}
+
+// interpreters maps the program named by a shebang line to languages.
+var interpreters = map[string]string{
+	"sh":      "bash",
+	"bash":    "bash",
+	"zsh":     "bash",
+	"dash":    "bash",
+	"python":  "python",
+	"python2": "python",
+	"python3": "python",
+	"node":    "javascript",
+	"deno":    "typescript",
+	"ts-node": "typescript",
+}
+
+type rule struct {
+	rx     *regexp.Regexp
+	weight int
+}
+
+func r(weight int, pattern string) rule {
+	return rule{rx: regexp.MustCompile(`(?m)` + pattern), weight: weight}
+}
+
+var javascriptRules = []rule{
+	r(2, `\b(const|let|var) \w+ = `),
+	r(1, `=>`),
+	r(2, `\bfunction\s*\w*\s*\(`),
+	r(4, `\bconsole\.(log|error|warn)\(`),
+	r(3, `\brequire\(['"]`),
+	r(3, `\b(document|window)\.\w+`),
+	r(2, `^export (default |const |function |class )`),
+	r(2, `^import .+ from ['"]`),
+	r(2, `===|!==`),
+}
+
+// languages lists the keyword rules for each language. When two languages
+// score the same, the one listed first wins.
+var languages = []struct {
+	id    string
+	rules []rule
+}{
+	{"go", []rule{
+		r(5, `^package \w+$`),
+		r(3, `^import \($`),
+		r(3, `\bfunc (\(\w+ \*?\w+\) )?\w+\(`),
+		r(2, `:=`),
+		r(2, `\b(fmt|errors|strings|http)\.[A-Z]\w*`),
+		r(3, `\berr != nil\b`),
+		r(2, `\bgo func\b|\bchan\b|\bdefer\b`),
+	}},
+	{"sql", []rule{
+		r(5, `(?i)^\s*(insert into|update \w+ set|delete from|create (table|index|view|unique index)|alter table|drop (table|index))\b`),
+		r(4, `(?i)\bselect\b[\s\S]+?\bfrom\b`),
+		r(1, `(?i)\b(where|inner join|left join|group by|order by|limit)\b`),
+		r(1, `(?i)\b(varchar|integer|not null|primary key)\b`),
+	}},
+	{"python", []rule{
+		r(4, `^\s*def \w+\(.*\)( -> [\w\[\], ]+)?:\s*$`),
+		r(4, `^\s*class \w+(\(.*\))?:\s*$`),
+		r(5, `^if __name__ == ['"]__main__['"]:`),
+		r(3, `^(from [\w.]+ )?import [\w.]+( as \w+)?$`),
+		r(2, `\bself\.\w+`),
+		r(3, `^\s*elif\b|\bNone\b|\bTrue\b|\bFalse\b`),
+		r(1, `\bprint\(`),
+	}},
+	{"javascript", javascriptRules},
+	{"typescript", append([]rule{
+		r(3, `\w+\??: (string|number|boolean|any|unknown|void)\b`),
+		r(4, `^\s*(export )?interface \w+( extends \w+)? \{`),
+		r(3, `^\s*(export )?type \w+ = `),
+	}, javascriptRules...)},
+	{"bash", []rule{
+		r(2, `^\s*(echo|export|cd|sudo|apt(-get)?|curl|wget|chmod|chown|mkdir|rm|systemctl|docker|kubectl|git)\s`),
+		r(4, `^\s*if \[\[? `),
+		r(3, `^\s*(fi|done|esac)\s*$`),
+		r(3, `\|\s*(grep|awk|sed|xargs|sort|uniq|wc|tee)\b`),
+		r(2, `\$\{\w+\}|\$\(\w+`),
+		r(1, `^\s*\w+=\S`),
+	}},
+	{"dockerfile", []rule{
+		r(5, `^FROM \S+`),
+		r(3, `^(RUN|CMD|COPY|ADD|ENTRYPOINT|WORKDIR|EXPOSE|ENV|ARG|USER|LABEL) `),
+	}},
+	{"nginx", []rule{
+		r(4, `^\s*(listen|server_name|proxy_pass|proxy_set_header|root|try_files|ssl_certificate)\s+[^;]+;`),
+		r(3, `^\s*(server|location|upstream|http|events)\b[^{;]*\{`),
+	}},
+	{"yaml", []rule{
+		r(3, `^---\s*$`),
+		r(1, `^[ \t]*[\w.-]+:([ \t]+[^{;\n]*)?$`),
+		r(1, `^[ \t]*- [\w"'./${]`),
+	}},
+	{"rust", []rule{
+		r(3, `\bfn \w+(<[^>]*>)?\(`),
+		r(4, `\blet mut\b`),
+		r(4, `\b(println|format|vec)!\(`),
+		r(3, `^use \w+(::\w+)+`),
+		r(2, `\bimpl\b|\bpub fn\b|&mut\b`),
+	}},
+	{"java", []rule{
+		r(5, `^import java\.`),
+		r(5, `\bSystem\.out\.print(ln)?\(`),
+		r(4, `\bpublic (static )?(final )?(class|void|interface)\b`),
+		r(2, `\b(private|protected) \w+(<[\w, ]+>)? \w+( =|;)`),
+	}},
+	{"c", []rule{
+		r(5, `^#include\s*[<"]`),
+		r(3, `\bint main\(`),
+		r(2, `\b(printf|malloc|free|sizeof)\(`),
+		r(1, `^#define \w+`),
+	}},
+	{"html", []rule{
+		r(5, `(?i)<!doctype html>`),
+		r(2, `(?i)</?(html|head|body|div|span|p|a|ul|li|table|script|link|meta)\b[^>]*>`),
+	}},
+	{"css", []rule{
+		r(2, `^\s*[.#]?[\w-]+([\s,>+~:]+[.#]?[\w-]+)*\s*\{\s*$`),
+		r(1, `^\s*[\w-]+:\s*[^;{]+;\s*$`),
+		r(3, `^@(media|import|font-face|keyframes)\b`),
+	}},
+	{"markdown", []rule{
+		r(2, "^```"),
+		r(1, `^#{1,6} \S`),
+		r(3, `\[[^\]]+\]\([^)\s]+\)`),
+		r(1, `^\s*[-*] \S`),
+	}},
+}
+
+// Language guesses the language of a snippet, returning one of the IDs in
+// highlight.Languages. A file name in the title wins over a shebang line,
+// which wins over keyword heuristics. If nothing matches it returns
+// highlight.PlainText.
+func Language(title, content string) string {
+	if lang, ok := fromTitle(title); ok {
+		return lang
+	}
+
+	if lang, ok := fromShebang(content); ok {
+		return lang
+	}
+
+	if trimmed := strings.TrimSpace(content); strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		if json.Valid([]byte(trimmed)) {
+			return "json"
+		}
+	}
+
+	lang := fromKeywords(content)
+	if lang == highlight.PlainText && looksLikeYAML(content) {
+		return "yaml"
+	}
+	return lang
+}
+
+// fromTitle looks for a file name, such as main.go or Dockerfile, among the
+// words of the title.
+func fromTitle(title string) (string, bool) {
+	for _, word := range strings.Fields(strings.ToLower(title)) {
+		word = strings.Trim(word, `"'()[],:;`)
+		base := path.Base(word)
+
+		if lang, ok := filenames[base]; ok {
+			return lang, true
+		}
+		if lang, ok := extensions[path.Ext(base)]; ok && base != path.Ext(base) {
+			return lang, true
+		}
+	}
+
+	return "", false
+}
+
+// fromShebang reads the interpreter from a #! first line, including the
+// "#!/usr/bin/env python3" form.
+func fromShebang(content string) (string, bool) {
+	line, _, _ := strings.Cut(content, "\n")
+	if !strings.HasPrefix(line, "#!") {
+		return "", false
+	}
+
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if len(fields) == 0 {
+		return "", false
+	}
+
+	program := path.Base(fields[0])
+	if program == "env" {
+		// Skip options such as env -S.
+		program = ""
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") {
+				program = path.Base(f)
+				break
+			}
+		}
+	}
+
+	lang, ok := interpreters[program]
+	if !ok {
+		lang, ok = interpreters[strings.TrimRight(program, "0123456789.")]
+	}
+	return lang, ok
+}
+
+var yamlLineRX = regexp.MustCompile(`^[ \t]*(#.*|[\w.-]+:([ \t]+[^{};]*)?|- .*)?$`)
+
+// looksLikeYAML reports whether every line of the content is a YAML key,
+// list item, comment or blank, and there are at least two keys. Short YAML
+// documents have too few distinctive constructs for the keyword rules.
+func looksLikeYAML(content string) bool {
+	if len(content) > sampleSize {
+		content = content[:sampleSize]
+	}
+
+	keys := 0
+	for _, line := range strings.Split(content, "\n") {
+		if !yamlLineRX.MatchString(line) {
+			return false
+		}
+		if t := strings.TrimSpace(line); t != "" && !strings.HasPrefix(t, "#") && !strings.HasPrefix(t, "- ") {
+			keys++
+		}
+	}
+
+	return keys >= 2
+}
+
+// fromKeywords scores the content against each language's rules and returns
+// the best scoring language, if any scores at least minScore.
+func fromKeywords(content string) string {
+	if len(content) > sampleSize {
+		content = content[:sampleSize]
+	}
+
+	best, bestScore := highlight.PlainText, minScore-1
+
+	for _, l := range languages {
+		score := 0
+		for _, rl := range l.rules {
+			score += rl.weight * len(rl.rx.FindAllStringIndex(content, maxMatches))
+		}
+
+		if score > bestScore {
+			best, bestScore = l.id, score
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,118 @@
+package detect
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/YelzhanWeb/snippetbox/internal/highlight"
+)
+
+// TestCorpus checks the keyword heuristics against the snippets in
+// testdata, which are filed in directories named after the language they
+// should be detected as.
+func TestCorpus(t *testing.T) {
+	files, err := filepath.Glob("testdata/*/*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no corpus files found")
+	}
+
+	for _, file := range files {
+		want := filepath.Base(filepath.Dir(file))
+
+		t.Run(strings.TrimPrefix(file, "testdata/"), func(t *testing.T) {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := Language("", string(content)); got != want {
+				t.Errorf("got %q; want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestCorpusLanguagesExist(t *testing.T) {
+	dirs, err := filepath.Glob("testdata/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dir := range dirs {
+		lang := filepath.Base(dir)
+		if !highlight.Supported(lang) {
+			t.Errorf("testdata/%s is not a supported language", lang)
+		}
+	}
+}
+
+func TestTitle(t *testing.T) {
+	tests := []struct {
+		title   string
+		content string
+		want    string
+	}{
+		{"main.go", "", "go"},
+		{"Handler for main.go", "print('not python')", "go"},
+		{"schema.SQL", "", "sql"},
+		{"docker-compose.yml", "", "yaml"},
+		{"(package.json)", "", "json"},
+		{"deploy.sh:", "", "bash"},
+		{"scripts/build.py", "", "python"},
+		{"app.mjs", "", "javascript"},
+		{"Dockerfile", "", "dockerfile"},
+		{"nginx.conf", "", "nginx"},
+		{"~/.bashrc", "", "bash"},
+		{".go", "", highlight.PlainText},
+		{"notes.unknown", "", highlight.PlainText},
+		{"Go tips", "", highlight.PlainText},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			if got := Language(tt.title, tt.content); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShebang(t *testing.T) {
+	tests := []struct {
+		name    string
+		title   string
+		content string
+		want    string
+	}{
+		{"sh", "", "#!/bin/sh\nls", "bash"},
+		{"bash", "", "#!/usr/bin/bash\n", "bash"},
+		{"env", "", "#!/usr/bin/env python3\nx = 1", "python"},
+		{"env options", "", "#!/usr/bin/env -S node --no-warnings\n", "javascript"},
+		{"versioned", "", "#!/usr/bin/python3.12\n", "python"},
+		{"deno", "", "#!/usr/bin/env deno\n", "typescript"},
+		{"unknown", "", "#!/usr/bin/perl\n", highlight.PlainText},
+		{"not first line", "", "\n#!/bin/sh\n", highlight.PlainText},
+		{"empty", "", "#!\n", highlight.PlainText},
+		{"title wins", "script.py", "#!/bin/sh\n", "python"},
+		{"beats keywords", "", "#!/bin/sh\npackage main\nfunc main() {}\n", "bash"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Language(tt.title, tt.content); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	if got := Language("", ""); got != highlight.PlainText {
+		t.Errorf("got %q; want %q", got, highlight.PlainText)
+	}
+}
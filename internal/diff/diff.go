@@ -0,0 +1,281 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxEdits bounds the work done by the Myers search. Inputs that differ by
+// more than this many lines are reported as a full replacement instead.
+const maxEdits = 2000
+
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+func (o Op) String() string {
+	switch o {
+	case Insert:
+		return "insert"
+	case Delete:
+		return "delete"
+	default:
+		return "equal"
+	}
+}
+
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// Prefix returns the marker used for the line in unified diff output.
+func (l Line) Prefix() string {
+	switch l.Op {
+	case Insert:
+		return "+"
+	case Delete:
+		return "-"
+	default:
+		return " "
+	}
+}
+
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []Line
+}
+
+// Header returns the hunk's "@@ -l,s +l,s @@" range line.
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+}
+
+// Unified compares a and b line by line and returns the differences grouped
+// into hunks, each surrounded by up to context unchanged lines. It returns
+// nil if the inputs are identical.
+func Unified(a, b string, context int) []Hunk {
+	lines := compare(split(a), split(b))
+
+	// Record the old and new line numbers at which every edit starts.
+	oldNo := make([]int, len(lines))
+	newNo := make([]int, len(lines))
+	o, n := 1, 1
+	for i, l := range lines {
+		oldNo[i], newNo[i] = o, n
+		if l.Op != Insert {
+			o++
+		}
+		if l.Op != Delete {
+			n++
+		}
+	}
+
+	var hunks []Hunk
+	for i := 0; i < len(lines); i++ {
+		if lines[i].Op == Equal {
+			continue
+		}
+
+		// Extend the group while the gap between changes is small enough
+		// for their context to overlap.
+		first, last := i, i
+		for j := i + 1; j < len(lines) && j-last <= 2*context+1; j++ {
+			if lines[j].Op != Equal {
+				last = j
+			}
+		}
+
+		start := max(first-context, 0)
+		end := min(last+context+1, len(lines))
+
+		h := Hunk{
+			OldStart: oldNo[start],
+			NewStart: newNo[start],
+			Lines:    lines[start:end],
+		}
+		for _, l := range h.Lines {
+			if l.Op != Insert {
+				h.OldLines++
+			}
+			if l.Op != Delete {
+				h.NewLines++
+			}
+		}
+		hunks = append(hunks, h.withEmptyStarts())
+
+		i = last
+	}
+
+	return hunks
+}
+
+// withEmptyStarts follows the unified format convention of reporting a
+// zero-length range as starting on the line before it.
+func (h Hunk) withEmptyStarts() Hunk {
+	if h.OldLines == 0 {
+		h.OldStart--
+	}
+	if h.NewLines == 0 {
+		h.NewStart--
+	}
+	return h
+}
+
+func split(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// compare returns the shortest edit script turning a into b, using the
+// linear space refinement of Myers' "An O(ND) Difference Algorithm": the
+// middle snake of the edit path is found by searching from both ends at
+// once, and the halves on either side of it are compared recursively.
+func compare(a, b []string) []Line {
+	limit := min(len(a)+len(b), maxEdits)
+	offset := (limit+1)/2 + 1
+
+	c := &comparer{
+		a:      a,
+		b:      b,
+		limit:  limit,
+		offset: offset,
+		vf:     make([]int, 2*offset+1),
+		vb:     make([]int, 2*offset+1),
+	}
+	if c.compare(0, len(a), 0, len(b)) {
+		return c.lines
+	}
+
+	lines := make([]Line, 0, len(a)+len(b))
+	for _, t := range a {
+		lines = append(lines, Line{Op: Delete, Text: t})
+	}
+	for _, t := range b {
+		lines = append(lines, Line{Op: Insert, Text: t})
+	}
+	return lines
+}
+
+type comparer struct {
+	a, b   []string
+	limit  int
+	offset int
+	vf, vb []int
+	lines  []Line
+}
+
+// compare appends the edit script turning a[x:n] into b[y:m]. It reports
+// false if that takes more than limit edits.
+func (c *comparer) compare(x, n, y, m int) bool {
+	for x < n && y < m && c.a[x] == c.b[y] {
+		c.lines = append(c.lines, Line{Op: Equal, Text: c.a[x]})
+		x++
+		y++
+	}
+	suffix := 0
+	for x < n-suffix && y < m-suffix && c.a[n-suffix-1] == c.b[m-suffix-1] {
+		suffix++
+	}
+	n, m = n-suffix, m-suffix
+
+	switch {
+	case x == n:
+		for _, t := range c.b[y:m] {
+			c.lines = append(c.lines, Line{Op: Insert, Text: t})
+		}
+	case y == m:
+		for _, t := range c.a[x:n] {
+			c.lines = append(c.lines, Line{Op: Delete, Text: t})
+		}
+	default:
+		sx, sy, ex, ey, ok := c.middleSnake(x, n, y, m)
+		if !ok {
+			return false
+		}
+		c.compare(x, sx, y, sy)
+		for _, t := range c.a[sx:ex] {
+			c.lines = append(c.lines, Line{Op: Equal, Text: t})
+		}
+		c.compare(ex, n, ey, m)
+	}
+
+	for _, t := range c.a[n : n+suffix] {
+		c.lines = append(c.lines, Line{Op: Equal, Text: t})
+	}
+	return true
+}
+
+// middleSnake finds the diagonal run at which the forward and backward
+// searches of a[x0:n] and b[y0:m] first overlap, and returns its start and
+// end. Diagonals are numbered x-y relative to the start of each search.
+func (c *comparer) middleSnake(x0, n, y0, m int) (sx, sy, ex, ey int, ok bool) {
+	a, b := c.a[x0:n], c.b[y0:m]
+	n, m = len(a), len(b)
+	delta := n - m
+	odd := delta%2 != 0
+	vf, vb, offset := c.vf, c.vb, c.offset
+
+	vf[offset+1], vb[offset+1] = 0, 0
+	for d := 0; d <= (c.limit+1)/2; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && vf[offset+k-1] < vf[offset+k+1]) {
+				x = vf[offset+k+1]
+			} else {
+				x = vf[offset+k-1] + 1
+			}
+			y := x - k
+			sx, sy := x, y
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			vf[offset+k] = x
+
+			if odd && -(d-1) <= delta-k && delta-k <= d-1 && x+vb[offset+delta-k] >= n {
+				if 2*d-1 > c.limit {
+					return 0, 0, 0, 0, false
+				}
+				return x0 + sx, y0 + sy, x0 + x, y0 + y, true
+			}
+		}
+
+		// The backward search runs over the reversed inputs, so diagonal k
+		// here meets diagonal delta-k of the forward search.
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && vb[offset+k-1] < vb[offset+k+1]) {
+				x = vb[offset+k+1]
+			} else {
+				x = vb[offset+k-1] + 1
+			}
+			y := x - k
+			ex, ey := n-x, m-y
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x++
+				y++
+			}
+			vb[offset+k] = x
+
+			if !odd && -d <= delta-k && delta-k <= d && x+vf[offset+delta-k] >= n {
+				if 2*d > c.limit {
+					return 0, 0, 0, 0, false
+				}
+				return x0 + n - x, y0 + m - y, x0 + ex, y0 + ey, true
+			}
+		}
+	}
+
+	return 0, 0, 0, 0, false
+}
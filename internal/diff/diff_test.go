@@ -0,0 +1,206 @@
+package diff
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// render formats hunks the way they are shown on the history page.
+func render(hunks []Hunk) string {
+	var b strings.Builder
+	for _, h := range hunks {
+		b.WriteString(h.Header() + "\n")
+		for _, l := range h.Lines {
+			b.WriteString(l.Prefix() + l.Text + "\n")
+		}
+	}
+	return b.String()
+}
+
+func numbered(from, to int) string {
+	var b strings.Builder
+	for i := from; i <= to; i++ {
+		fmt.Fprintf(&b, "%d\n", i)
+	}
+	return b.String()
+}
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    string
+		context int
+		want    string
+	}{
+		{
+			name: "identical",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			want: "",
+		},
+		{
+			name: "both empty",
+			want: "",
+		},
+		{
+			name: "from empty",
+			b:    "a\nb\n",
+			want: "@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "to empty",
+			a:    "a\nb\n",
+			want: "@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name:    "change in the middle",
+			a:       numbered(1, 9),
+			b:       strings.Replace(numbered(1, 9), "5\n", "five\n", 1),
+			context: 2,
+			want:    "@@ -3,5 +3,5 @@\n 3\n 4\n-5\n+five\n 6\n 7\n",
+		},
+		{
+			name:    "context clipped at the edges",
+			a:       "a\nb\nc\n",
+			b:       "A\nb\nC\n",
+			context: 3,
+			want:    "@@ -1,3 +1,3 @@\n-a\n+A\n b\n-c\n+C\n",
+		},
+		{
+			name:    "overlapping context merges hunks",
+			a:       numbered(1, 20),
+			b:       strings.Replace(strings.Replace(numbered(1, 20), "\n5\n", "\n", 1), "\n10\n", "\n", 1),
+			context: 2,
+			want:    "@@ -3,10 +3,8 @@\n 3\n 4\n-5\n 6\n 7\n 8\n 9\n-10\n 11\n 12\n",
+		},
+		{
+			name:    "distant changes get separate hunks",
+			a:       numbered(1, 20),
+			b:       strings.Replace(strings.Replace(numbered(1, 20), "\n4\n", "\n", 1), "\n16\n", "\n", 1),
+			context: 2,
+			want:    "@@ -2,5 +2,4 @@\n 2\n 3\n-4\n 5\n 6\n@@ -14,5 +13,4 @@\n 14\n 15\n-16\n 17\n 18\n",
+		},
+		{
+			name:    "insertion without context",
+			a:       "a\nc\n",
+			b:       "a\nb\nc\n",
+			context: 0,
+			want:    "@@ -1,0 +2,1 @@\n+b\n",
+		},
+		{
+			name:    "line endings and final newline are ignored",
+			a:       "a\r\nb\r\n",
+			b:       "a\nb",
+			context: 3,
+			want:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := render(Unified(tt.a, tt.b, tt.context)); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnifiedIdenticalIsNil(t *testing.T) {
+	if hunks := Unified("a\nb\n", "a\nb\n", 3); hunks != nil {
+		t.Errorf("got %d hunks; want nil", len(hunks))
+	}
+}
+
+func TestCompareIsShortest(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		edits int
+	}{
+		{"abcabba", "cbabac", 5},
+		{"abcdef", "abcdef", 0},
+		{"abc", "xyz", 6},
+		{"aaaa", "aa", 2},
+		{"xaxbxc", "abc", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			a, b := strings.Split(tt.a, ""), strings.Split(tt.b, "")
+			lines := compare(a, b)
+
+			var old, new []string
+			edits := 0
+			for _, l := range lines {
+				if l.Op != Insert {
+					old = append(old, l.Text)
+				}
+				if l.Op != Delete {
+					new = append(new, l.Text)
+				}
+				if l.Op != Equal {
+					edits++
+				}
+			}
+
+			if strings.Join(old, "") != tt.a || strings.Join(new, "") != tt.b {
+				t.Errorf("script turns %q into %q; want %q into %q", strings.Join(old, ""), strings.Join(new, ""), tt.a, tt.b)
+			}
+			if edits != tt.edits {
+				t.Errorf("got %d edits; want %d", edits, tt.edits)
+			}
+		})
+	}
+}
+
+// TestCompareMemory checks that comparing revisions which differ by nearly
+// maxEdits lines uses memory in proportion to the input, not to the square
+// of the number of edits.
+func TestCompareMemory(t *testing.T) {
+	a := make([]string, 2*maxEdits)
+	b := make([]string, 2*maxEdits)
+	for i := range a {
+		a[i] = fmt.Sprint(i)
+		b[i] = fmt.Sprint(i)
+		if i%4 == 0 {
+			b[i] = "changed"
+		}
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	lines := compare(a, b)
+	runtime.ReadMemStats(&after)
+
+	edits := 0
+	for _, l := range lines {
+		if l.Op != Equal {
+			edits++
+		}
+	}
+	if edits != maxEdits {
+		t.Errorf("got %d edits; want %d", edits, maxEdits)
+	}
+
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 1<<20 {
+		t.Errorf("allocated %d bytes; want at most 1 MiB", alloc)
+	}
+}
+
+func TestCompareTooManyEdits(t *testing.T) {
+	a := strings.Split(numbered(1, maxEdits), "\n")
+	b := strings.Split(numbered(maxEdits+1, 2*maxEdits), "\n")
+	b[len(b)-1] = "different"
+
+	lines := compare(a, b)
+	for i, l := range lines {
+		want := Delete
+		if i >= len(a) {
+			want = Insert
+		}
+		if l.Op != want {
+			t.Fatalf("line %d is %s; want a full replacement", i, l.Op)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/YelzhanWeb/snippetbox/internal/app"
+	"github.com/YelzhanWeb/snippetbox/internal/models"
+	"github.com/YelzhanWeb/snippetbox/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+type tokenCreateForm struct {
+	Name                string `form:"name"`
+	Scope               string `form:"scope"`
+	Expires             int    `form:"expires"`
+	validator.Validator `form:"-"`
+}
+
+func Account(app *app.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data := app.NewTemplateData(r)
+		data.Form = tokenCreateForm{
+			Scope:   models.ScopeRead,
+			Expires: 90,
+		}
+
+		renderAccount(app, w, r, http.StatusOK, data)
+	}
+}
+
+func AccountTokenCreatePost(app *app.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var form tokenCreateForm
+
+		err := app.DecodePostForm(r, &form)
+		if err != nil {
+			app.ClientError(w, http.StatusBadRequest)
+			return
+		}
+
+		form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+		form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+		form.CheckField(validator.PermittedValue(form.Scope, models.ScopeRead, models.ScopeWrite), "scope", "This field must equal read or write")
+		form.CheckField(validator.PermittedValue(form.Expires, 0, 30, 90, 365), "expires", "This field must equal 0, 30, 90 or 365")
+
+		if !form.Valid() {
+			data := app.NewTemplateData(r)
+			data.Form = form
+			renderAccount(app, w, r, http.StatusUnprocessableEntity, data)
+			return
+		}
+
+		token, err := app.Tokens.Insert(app.AuthenticatedUserID(r), form.Name, form.Scope, form.Expires)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		// The plaintext token can't be recovered later, so it is shown once
+		// on the next page load and then discarded.
+		app.SessionManager.Put(r.Context(), "newToken", token)
+
+		http.Redirect(w, r, "/account", http.StatusSeeOther)
+	}
+}
+
+func AccountTokenDeletePost(app *app.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := httprouter.ParamsFromContext(r.Context())
+
+		id, err := strconv.Atoi(params.ByName("id"))
+		if err != nil || id < 1 {
+			app.NotFound(w)
+			return
+		}
+
+		err = app.Tokens.Delete(id, app.AuthenticatedUserID(r))
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.NotFound(w)
+			} else {
+				app.ServerError(w, r, err)
+			}
+			return
+		}
+
+		app.SessionManager.Put(r.Context(), "flash", "Token successfully revoked!")
+
+		http.Redirect(w, r, "/account", http.StatusSeeOther)
+	}
+}
+
+func renderAccount(app *app.Application, w http.ResponseWriter, r *http.Request, status int, data *models.TemplData) {
+	userID := app.AuthenticatedUserID(r)
+
+	user, err := app.Users.Get(userID)
+	if err != nil {
+		app.ServerError(w, r, err)
+		return
+	}
+
+	tokens, err := app.Tokens.ForUser(userID)
+	if err != nil {
+		app.ServerError(w, r, err)
+		return
+	}
+
+	snippets, err := app.Snippets.ByUser(userID)
+	if err != nil {
+		app.ServerError(w, r, err)
+		return
+	}
+
+	data.User = user
+	data.Tokens = tokens
+	data.Snippets = snippets
+	data.NewToken = app.SessionManager.PopString(r.Context(), "newToken")
+
+	app.Render(w, r, status, "account.tmpl.html", data)
+}
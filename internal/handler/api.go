@@ -0,0 +1,263 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/YelzhanWeb/snippetbox/internal/app"
+	"github.com/YelzhanWeb/snippetbox/internal/detect"
+	"github.com/YelzhanWeb/snippetbox/internal/highlight"
+	"github.com/YelzhanWeb/snippetbox/internal/models"
+	"github.com/YelzhanWeb/snippetbox/internal/validator"
+)
+
+// envelope is an alias for app.Envelope, which is shadowed by the app
+// parameter inside the handler closures.
+type envelope = app.Envelope
+
+func APISnippetList(app *app.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, v := readPageRequest(r.URL.Query())
+		if !v.Valid() {
+			app.FailedValidationJSON(w, v)
+			return
+		}
+
+		var err error
+
+		var page *models.SnippetPage
+		if tag := r.URL.Query().Get("tag"); tag != "" {
+			page, err = app.Snippets.ByTag(strings.ToLower(tag), req)
+		} else {
+			page, err = app.Snippets.List(req)
+		}
+		if err != nil {
+			app.ServerErrorJSON(w, r, err)
+			return
+		}
+
+		app.WriteJSON(w, http.StatusOK, envelope{
+			"snippets": page.Snippets,
+			"metadata": envelope{
+				"sort":        page.Sort,
+				"size":        page.Size,
+				"next_cursor": page.Next,
+				"prev_cursor": page.Prev,
+			},
+		}, nil)
+	}
+}
+
+func APISnippetGet(app *app.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snippet, ok := apiUnlockedSnippet(app, w, r)
+		if !ok {
+			return
+		}
+
+		if snippet.BurnsFor(app.AuthenticatedUserID(r)) {
+			app.ClientErrorMessageJSON(w, http.StatusForbidden, "this snippet has limited views; POST to its reveal endpoint to view it")
+			return
+		}
+
+		app.WriteJSON(w, http.StatusOK, envelope{"snippet": snippet}, nil)
+	}
+}
+
+func APISnippetReveal(app *app.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snippet, ok := apiUnlockedSnippet(app, w, r)
+		if !ok {
+			return
+		}
+
+		if !snippet.BurnsFor(app.AuthenticatedUserID(r)) {
+			app.WriteJSON(w, http.StatusOK, envelope{"snippet": snippet}, nil)
+			return
+		}
+
+		revealed, err := app.Snippets.Reveal(snippet.ID)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.NotFoundJSON(w)
+			} else {
+				app.ServerErrorJSON(w, r, err)
+			}
+			return
+		}
+
+		headers := make(http.Header)
+		headers.Set("Cache-Control", "no-store")
+
+		app.WriteJSON(w, http.StatusOK, envelope{"snippet": revealed}, headers)
+	}
+}
+
+func APISnippetCreate(app *app.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var form snippetCreateForm
+
+		err := app.ReadJSON(w, r, &form)
+		if err != nil {
+			app.BadRequestJSON(w, err)
+			return
+		}
+
+		form.validate(app, nil)
+
+		if !form.Valid() {
+			app.FailedValidationJSON(w, form.Validator)
+			return
+		}
+
+		slug, err := app.Snippets.Insert(form.fields(), app.AuthenticatedUserID(r))
+		if err != nil {
+			app.ServerErrorJSON(w, r, err)
+			return
+		}
+		app.Metrics.SnippetsCreated.Inc()
+
+		snippet, err := app.Snippets.GetBySlug(slug)
+		if err != nil {
+			app.ServerErrorJSON(w, r, err)
+			return
+		}
+
+		headers := make(http.Header)
+		headers.Set("Location", fmt.Sprintf("/api/v1/snippets/%s", slug))
+
+		app.WriteJSON(w, http.StatusCreated, envelope{"snippet": snippet}, headers)
+	}
+}
+
+func APISnippetUpdate(app *app.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snippet, ok := apiOwnedSnippet(app, w, r)
+		if !ok {
+			return
+		}
+
+		var form snippetCreateForm
+
+		err := app.ReadJSON(w, r, &form)
+		if err != nil {
+			app.BadRequestJSON(w, err)
+			return
+		}
+
+		form.validate(app, snippet)
+
+		if !form.Valid() {
+			app.FailedValidationJSON(w, form.Validator)
+			return
+		}
+
+		err = app.Snippets.Update(snippet.ID, form.fields())
+		if err != nil {
+			app.ServerErrorJSON(w, r, err)
+			return
+		}
+
+		snippet, err = app.Snippets.Get(snippet.ID)
+		if err != nil {
+			app.ServerErrorJSON(w, r, err)
+			return
+		}
+
+		app.WriteJSON(w, http.StatusOK, envelope{"snippet": snippet}, nil)
+	}
+}
+
+func APISnippetDelete(app *app.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snippet, ok := apiOwnedSnippet(app, w, r)
+		if !ok {
+			return
+		}
+
+		err := app.Snippets.Delete(snippet.ID)
+		if err != nil {
+			app.ServerErrorJSON(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func APIDetect(app *app.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input struct {
+			Title   string `json:"title"`
+			Content string `json:"content"`
+		}
+
+		err := app.ReadJSON(w, r, &input)
+		if err != nil {
+			app.BadRequestJSON(w, err)
+			return
+		}
+
+		var v validator.Validator
+		v.CheckField(validator.NotBlank(input.Title) || validator.NotBlank(input.Content), "content", "Either title or content must be provided")
+
+		if !v.Valid() {
+			app.FailedValidationJSON(w, v)
+			return
+		}
+
+		language := detect.Language(input.Title, input.Content)
+
+		app.WriteJSON(w, http.StatusOK, envelope{
+			"language": language,
+			"name":     highlight.Name(language),
+		}, nil)
+	}
+}
+
+func APICurrentUser(app *app.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := app.Users.Get(app.AuthenticatedUserID(r))
+		if err != nil {
+			app.ServerErrorJSON(w, r, err)
+			return
+		}
+
+		app.WriteJSON(w, http.StatusOK, envelope{"user": user}, nil)
+	}
+}
+
+// apiRouteSnippet is the JSON counterpart of routeSnippet.
+func apiRouteSnippet(app *app.Application, w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, redirect, err := lookupSnippet(app, r)
+	switch {
+	case errors.Is(err, models.ErrNoRecord):
+		app.NotFoundJSON(w)
+		return nil, false
+	case err != nil:
+		app.ServerErrorJSON(w, r, err)
+		return nil, false
+	case redirect != "":
+		permanentRedirect(w, r, redirect)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// apiOwnedSnippet is the JSON counterpart of ownedSnippet.
+func apiOwnedSnippet(app *app.Application, w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, ok := apiRouteSnippet(app, w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if snippet.UserID != app.AuthenticatedUserID(r) {
+		app.ClientErrorJSON(w, http.StatusForbidden)
+		return nil, false
+	}
+
+	return snippet, true
+}
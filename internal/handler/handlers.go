@@ -1,22 +1,201 @@
 package handler
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/YelzhanWeb/snippetbox/internal/app"
+	"github.com/YelzhanWeb/snippetbox/internal/detect"
+	"github.com/YelzhanWeb/snippetbox/internal/diff"
+	"github.com/YelzhanWeb/snippetbox/internal/highlight"
 	"github.com/YelzhanWeb/snippetbox/internal/models"
 	"github.com/YelzhanWeb/snippetbox/internal/validator"
 	"github.com/julienschmidt/httprouter"
 )
 
+// snippetCreateForm is decoded from both HTML forms and JSON. HTML forms send
+// tags as a single comma or space separated TagList, which is split into Tags
+// by splitTags.
 type snippetCreateForm struct {
-	Title               string `form:"title"`
-	Content             string `form:"content"`
-	Expires             int    `form:"expires"`
-	validator.Validator `form:"-"`
+	Title               string      `form:"title" json:"title"`
+	Content             string      `form:"content" json:"content"`
+	Language            string      `form:"language" json:"language"`
+	Visibility          string      `form:"visibility" json:"visibility"`
+	MaxViews            int         `form:"max_views" json:"max_views"`
+	Expires             expiryInput `form:"expires" json:"expires"`
+	ExpiresAt           string      `form:"expires_at" json:"expires_at"`
+	TagList             string      `form:"tags" json:"-"`
+	Tags                []string    `form:"-" json:"tags"`
+	Password            string      `form:"password" json:"password"`
+	Encrypted           bool        `form:"encrypted" json:"encrypted"`
+	validator.Validator `form:"-" json:"-"`
+
+	expires time.Time
+}
+
+const (
+	expiresNever = "never"
+	expiresAt    = "at"
+)
+
+// expiryInput is a snippet lifetime as accepted by models.ParseDuration,
+// expiresNever, or expiresAt to expire at the time given in ExpiresAt. JSON
+// clients may also send a number of days as a number, as they did before
+// other lifetimes were supported.
+type expiryInput string
+
+func (e *expiryInput) UnmarshalJSON(data []byte) error {
+	var days int
+	if json.Unmarshal(data, &days) == nil {
+		*e = expiryInput(strconv.Itoa(days))
+		return nil
+	}
+
+	var s string
+	err := json.Unmarshal(data, &s)
+	*e = expiryInput(s)
+	return err
+}
+
+// expiresAtLayouts are the accepted formats of exact expiry times. Times
+// without a zone are taken to be UTC.
+var expiresAtLayouts = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02T15:04:05"}
+
+func parseExpiresAt(value string) (time.Time, error) {
+	var err error
+	for _, layout := range expiresAtLayouts {
+		var t time.Time
+		t, err = time.Parse(layout, value)
+		if err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, err
+}
+
+func (form *snippetCreateForm) fields() models.SnippetFields {
+	return models.SnippetFields{
+		Title:      form.Title,
+		Content:    form.Content,
+		Language:   form.Language,
+		Visibility: form.Visibility,
+		MaxViews:   form.MaxViews,
+		Expires:    form.expires,
+		Tags:       form.Tags,
+		Password:   form.Password,
+		Encrypted:  form.Encrypted,
+	}
+}
+
+func (form *snippetCreateForm) splitTags() {
+	form.Tags = strings.FieldsFunc(form.TagList, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+}
+
+// validate checks the form, filling in defaults. Passwords and encryption
+// can only be set when a snippet is created, so when editing an existing
+// snippet the form's are replaced by the snippet's own. Existing snippets
+// also keep their expiry time if the form doesn't give a new one, and can't
+// be limited to fewer views than they have already had.
+func (form *snippetCreateForm) validate(app *app.Application, existing *models.Snippet) {
+	form.Tags = models.NormalizeTags(form.Tags)
+
+	protected := form.Password != ""
+	if existing != nil {
+		form.Password = ""
+		form.Encrypted = existing.Encrypted
+		protected = existing.Protected
+	}
+
+	if form.Visibility == "" {
+		form.Visibility = models.VisibilityPublic
+	}
+
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	form.validateExpiry(app, existing)
+	form.CheckField(validator.MaxItems(form.Tags, models.MaxTags), "tags", fmt.Sprintf("This field cannot have more than %d tags", models.MaxTags))
+	form.CheckField(validator.AllMaxChars(form.Tags, models.MaxTagLength), "tags", fmt.Sprintf("Tags cannot be more than %d characters long", models.MaxTagLength))
+	form.CheckField(validator.AllMatch(form.Tags, validator.TagRX), "tags", "Tags may only contain letters, digits and the characters + # . _ -")
+	form.CheckField(form.Language == "" || highlight.Supported(form.Language), "language", "This field must be a supported language")
+	form.CheckField(validator.PermittedValue(form.Visibility, models.Visibilities...), "visibility", "This field must be public, unlisted or private")
+	form.CheckField(form.MaxViews >= 0 && form.MaxViews <= 100, "max_views", "This field must be between 0 and 100")
+	if existing != nil && form.MaxViews != 0 {
+		form.CheckField(form.MaxViews > existing.Views, "max_views", fmt.Sprintf("This field must be more than the %d views so far", existing.Views))
+	}
+	form.CheckField(form.MaxViews == 0 || form.Visibility != models.VisibilityPublic, "visibility", "Snippets which self-destruct can't be public")
+	form.CheckField(len(form.Password) <= 72, "password", "This field cannot be more than 72 bytes long")
+	form.CheckField(!protected || form.Visibility != models.VisibilityPublic, "visibility", "Password-protected snippets can't be public")
+
+	if form.Encrypted {
+		form.CheckField(validator.Matches(form.Content, validator.EncryptedRX), "content", "This field must be encrypted in the browser")
+
+		// The server can't see the content of encrypted snippets to detect
+		// its language.
+		if form.Language == "" {
+			form.Language = highlight.PlainText
+		}
+	}
+
+	if form.Valid() && form.Language == "" {
+		form.Language = detect.Language(form.Title, form.Content)
+	}
+}
+
+func (form *snippetCreateForm) validateExpiry(app *app.Application, existing *models.Snippet) {
+	now := time.Now().UTC()
+
+	if form.Expires == "" && form.ExpiresAt != "" {
+		form.Expires = expiresAt
+	}
+
+	switch form.Expires {
+	case "":
+		if existing == nil {
+			form.AddFieldError("expires", "This field cannot be blank")
+			return
+		}
+		form.expires = existing.Expires
+		return
+
+	case expiresNever:
+		form.CheckField(app.AllowNeverExpire, "expires", "Snippets must expire")
+		form.expires = models.Never
+		return
+
+	case expiresAt:
+		t, err := parseExpiresAt(form.ExpiresAt)
+		if err != nil {
+			form.AddFieldError("expires_at", "This field must be a date and time such as 2030-01-31T12:00")
+			return
+		}
+		form.expires = t
+
+	default:
+		d, err := models.ParseDuration(string(form.Expires))
+		if err != nil {
+			form.AddFieldError("expires", "This field must be a lifetime such as 10m, 1h or 30d, or never")
+			return
+		}
+		form.expires = now.Add(d)
+	}
+
+	key := "expires"
+	if form.Expires == expiresAt {
+		key = "expires_at"
+	}
+
+	form.CheckField(form.expires.After(now), key, "Snippets must expire in the future")
+	form.CheckField(!form.expires.After(now.Add(app.MaxExpiry)), key,
+		fmt.Sprintf("Snippets cannot expire more than %s from now", models.FormatDuration(app.MaxExpiry)))
 }
 
 type userSignupForm struct {
@@ -41,41 +220,130 @@ func Home(app *app.Application) http.HandlerFunc {
 
 		snippets, err := app.Snippets.Latest()
 		if err != nil {
-			app.ServerError(w, err)
+			app.ServerError(w, r, err)
+			return
+		}
+
+		tags, err := app.Snippets.Tags(30)
+		if err != nil {
+			app.ServerError(w, r, err)
 			return
 		}
 
 		data := app.NewTemplateData(r)
 		data.Snippets = snippets
+		data.Tags = tags
 
-		app.Render(w, http.StatusOK, "home.tmpl.html", data)
+		app.Render(w, r, http.StatusOK, "home.tmpl.html", data)
 	}
 }
 
 func SnippetView(app *app.Application) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		params := httprouter.ParamsFromContext(r.Context())
+		snippet, ok := routeSnippet(app, w, r)
+		if !ok {
+			return
+		}
 
-		id, err := strconv.Atoi(params.ByName("id"))
-		if err != nil || id < 1 {
-			app.NotFound(w)
+		data := app.NewTemplateData(r)
+		data.Snippet = snippet
+
+		if !unlocked(app, r, snippet) {
+			data.Form = snippetUnlockForm{}
+			w.Header().Set("Cache-Control", "no-store")
+			app.Render(w, r, http.StatusOK, "unlock.tmpl.html", data)
+			return
+		}
+
+		// Snippets with limited views are only revealed by a POST, so that
+		// link previews and crawlers don't use up their views.
+		if snippet.BurnsFor(app.AuthenticatedUserID(r)) {
+			w.Header().Set("Cache-Control", "no-store")
+			app.Render(w, r, http.StatusOK, "reveal.tmpl.html", data)
+			return
+		}
+
+		app.Render(w, r, http.StatusOK, "view.tmpl.html", data)
+	}
+}
+
+func SnippetRevealPost(app *app.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snippet, ok := routeSnippet(app, w, r)
+		if !ok {
 			return
 		}
 
-		snippet, err := app.Snippets.Get(id)
+		if !unlocked(app, r, snippet) || !snippet.BurnsFor(app.AuthenticatedUserID(r)) {
+			http.Redirect(w, r, "/snippet/view/"+snippet.Slug, http.StatusSeeOther)
+			return
+		}
+
+		revealed, err := app.Snippets.Reveal(snippet.ID)
 		if err != nil {
 			if errors.Is(err, models.ErrNoRecord) {
 				app.NotFound(w)
 			} else {
-				app.ServerError(w, err)
+				app.ServerError(w, r, err)
 			}
 			return
 		}
 
+		data := app.NewTemplateData(r)
+		data.Snippet = revealed
+		data.Revealed = true
+
+		w.Header().Set("Cache-Control", "no-store")
+		app.Render(w, r, http.StatusOK, "view.tmpl.html", data)
+	}
+}
+
+func SnippetHistory(app *app.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snippet, ok := readableSnippet(app, w, r)
+		if !ok {
+			return
+		}
+
+		// The revisions of encrypted snippets are ciphertext, which can't be
+		// compared, so they have no history page.
+		if snippet.Encrypted {
+			http.Redirect(w, r, "/snippet/view/"+snippet.Slug, http.StatusSeeOther)
+			return
+		}
+
+		revisions, err := app.Snippets.History(snippet)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
 		data := app.NewTemplateData(r)
 		data.Snippet = snippet
+		data.Revisions = revisions
+
+		query := r.URL.Query()
+		if query.Has("from") || query.Has("to") {
+			from, err := strconv.Atoi(query.Get("from"))
+			if err != nil || from < 1 || from > len(revisions) {
+				app.ClientError(w, http.StatusBadRequest)
+				return
+			}
+
+			to, err := strconv.Atoi(query.Get("to"))
+			if err != nil || to < 1 || to > len(revisions) {
+				app.ClientError(w, http.StatusBadRequest)
+				return
+			}
 
-		app.Render(w, http.StatusOK, "view.tmpl.html", data)
+			data.Diff = &models.RevisionDiff{
+				From:  revisions[from-1],
+				To:    revisions[to-1],
+				Hunks: diff.Unified(revisions[from-1].Content, revisions[to-1].Content, 3),
+			}
+		}
+
+		app.Render(w, r, http.StatusOK, "history.tmpl.html", data)
 	}
 }
 
@@ -83,10 +351,11 @@ func SnippetCreate(app *app.Application) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		data := app.NewTemplateData(r)
 		data.Form = snippetCreateForm{
-			Expires: 365,
+			Visibility: models.VisibilityPublic,
+			Expires:    "365d",
 		}
 
-		app.Render(w, http.StatusOK, "create.tmpl.html", data)
+		app.Render(w, r, http.StatusOK, "create.tmpl.html", data)
 	}
 
 }
@@ -102,34 +371,220 @@ func SnippetCreatePost(app *app.Application) http.HandlerFunc {
 			return
 		}
 
-		form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
-		form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
-		form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
-		form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
+		form.splitTags()
+		form.validate(app, nil)
 
 		if !form.Valid() {
 			data := app.NewTemplateData(r)
 			data.Form = form
-			app.Render(w, http.StatusUnprocessableEntity, "create.tmpl.html", data)
+			app.Render(w, r, http.StatusUnprocessableEntity, "create.tmpl.html", data)
 			return
 		}
-		id, err := app.Snippets.Insert(form.Title, form.Content, form.Expires)
+		slug, err := app.Snippets.Insert(form.fields(), app.AuthenticatedUserID(r))
 		if err != nil {
-			app.ServerError(w, err)
+			app.ServerError(w, r, err)
 			return
 		}
+		app.Metrics.SnippetsCreated.Inc()
 
 		app.SessionManager.Put(r.Context(), "flash", "Snippet successfully created!")
 
-		http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+		http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s", slug), http.StatusSeeOther)
+	}
+}
+
+func SnippetEdit(app *app.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snippet, ok := ownedSnippet(app, w, r)
+		if !ok {
+			return
+		}
+
+		data := app.NewTemplateData(r)
+		data.Snippet = snippet
+		data.Form = snippetCreateForm{
+			Title:      snippet.Title,
+			Content:    snippet.Content,
+			Language:   snippet.Language,
+			Visibility: snippet.Visibility,
+			MaxViews:   snippet.MaxViews,
+			Encrypted:  snippet.Encrypted,
+			TagList:    strings.Join(snippet.Tags, " "),
+		}
+
+		app.Render(w, r, http.StatusOK, "edit.tmpl.html", data)
+	}
+}
+
+func SnippetEditPost(app *app.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snippet, ok := ownedSnippet(app, w, r)
+		if !ok {
+			return
+		}
+
+		var form snippetCreateForm
+
+		err := app.DecodePostForm(r, &form)
+		if err != nil {
+			app.ClientError(w, http.StatusBadRequest)
+			return
+		}
+
+		form.splitTags()
+		form.validate(app, snippet)
+
+		if !form.Valid() {
+			data := app.NewTemplateData(r)
+			data.Snippet = snippet
+			data.Form = form
+			app.Render(w, r, http.StatusUnprocessableEntity, "edit.tmpl.html", data)
+			return
+		}
+
+		err = app.Snippets.Update(snippet.ID, form.fields())
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		app.SessionManager.Put(r.Context(), "flash", "Snippet successfully updated!")
+
+		http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s", snippet.Slug), http.StatusSeeOther)
+	}
+}
+
+func SnippetDeletePost(app *app.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snippet, ok := ownedSnippet(app, w, r)
+		if !ok {
+			return
+		}
+
+		err := app.Snippets.Delete(snippet.ID)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		app.SessionManager.Put(r.Context(), "flash", "Snippet successfully deleted!")
+
+		http.Redirect(w, r, "/", http.StatusSeeOther)
 	}
 }
 
+// lookupSnippet finds the snippet named by the :slug route parameter, as
+// seen by the requesting user. It returns models.ErrNoRecord if the snippet
+// doesn't exist or is private to someone else.
+//
+// Integer parameters are IDs from URLs made before snippets had slugs. For
+// those it returns the snippet's current URL to redirect to instead, but only
+// for public snippets and the user's own: redirecting for the rest would let
+// unlisted snippets be found by counting through IDs.
+func lookupSnippet(app *app.Application, r *http.Request) (*models.Snippet, string, error) {
+	param := httprouter.ParamsFromContext(r.Context()).ByName("slug")
+	userID := app.AuthenticatedUserID(r)
+
+	id, err := strconv.Atoi(param)
+	if err != nil {
+		snippet, err := app.Snippets.GetBySlug(param)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if !snippet.VisibleTo(userID) {
+			return nil, "", models.ErrNoRecord
+		}
+
+		return snippet, "", nil
+	}
+
+	snippet, err := app.Snippets.Get(id)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if snippet.Visibility != models.VisibilityPublic && snippet.UserID != userID {
+		return nil, "", models.ErrNoRecord
+	}
+
+	url := strings.Replace(r.URL.Path, "/"+param, "/"+snippet.Slug, 1)
+	if r.URL.RawQuery != "" {
+		url += "?" + r.URL.RawQuery
+	}
+
+	return nil, url, nil
+}
+
+// permanentRedirect redirects to url, keeping the method and body of
+// requests other than GET and HEAD.
+func permanentRedirect(w http.ResponseWriter, r *http.Request, url string) {
+	status := http.StatusMovedPermanently
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		status = http.StatusPermanentRedirect
+	}
+
+	http.Redirect(w, r, url, status)
+}
+
+// routeSnippet calls lookupSnippet. If there is no snippet to show, the
+// redirect or the appropriate error response is written and ok is false.
+func routeSnippet(app *app.Application, w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, redirect, err := lookupSnippet(app, r)
+	switch {
+	case errors.Is(err, models.ErrNoRecord):
+		app.NotFound(w)
+		return nil, false
+	case err != nil:
+		app.ServerError(w, r, err)
+		return nil, false
+	case redirect != "":
+		permanentRedirect(w, r, redirect)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// readableSnippet is routeSnippet for pages which show a snippet's content
+// without using up one of its views. Locked snippets and snippets with
+// limited views are redirected to their view page instead.
+func readableSnippet(app *app.Application, w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, ok := routeSnippet(app, w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if !unlocked(app, r, snippet) || snippet.BurnsFor(app.AuthenticatedUserID(r)) {
+		http.Redirect(w, r, "/snippet/view/"+snippet.Slug, http.StatusSeeOther)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// ownedSnippet looks up the snippet named by the :slug route parameter and
+// checks that it belongs to the authenticated user. If it doesn't, the
+// appropriate error response is written and ok is false.
+func ownedSnippet(app *app.Application, w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, ok := routeSnippet(app, w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if snippet.UserID != app.AuthenticatedUserID(r) {
+		app.ClientError(w, http.StatusForbidden)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
 func UserSignup(app *app.Application) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		data := app.NewTemplateData(r)
 		data.Form = userSignupForm{}
-		app.Render(w, http.StatusOK, "signup.tmpl.html", data)
+		app.Render(w, r, http.StatusOK, "signup.tmpl.html", data)
 	}
 }
 
@@ -152,7 +607,7 @@ func UserSignupPost(app *app.Application) http.HandlerFunc {
 		if !form.Valid() {
 			data := app.NewTemplateData(r)
 			data.Form = form
-			app.Render(w, http.StatusUnprocessableEntity, "signup.tmpl.html", data)
+			app.Render(w, r, http.StatusUnprocessableEntity, "signup.tmpl.html", data)
 			return
 		}
 
@@ -162,9 +617,9 @@ func UserSignupPost(app *app.Application) http.HandlerFunc {
 				form.AddFieldError("email", "Email address is already in use")
 				data := app.NewTemplateData(r)
 				data.Form = form
-				app.Render(w, http.StatusUnprocessableEntity, "signup.tmpl.html", data)
+				app.Render(w, r, http.StatusUnprocessableEntity, "signup.tmpl.html", data)
 			} else {
-				app.ServerError(w, err)
+				app.ServerError(w, r, err)
 			}
 			return
 		}
@@ -180,7 +635,7 @@ func UserLogin(app *app.Application) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		data := app.NewTemplateData(r)
 		data.Form = userLoginForm{}
-		app.Render(w, http.StatusOK, "login.tmpl.html", data)
+		app.Render(w, r, http.StatusOK, "login.tmpl.html", data)
 	}
 }
 
@@ -200,30 +655,32 @@ func UserLoginPost(app *app.Application) http.HandlerFunc {
 		if !form.Valid() {
 			data := app.NewTemplateData(r)
 			data.Form = form
-			app.Render(w, http.StatusUnprocessableEntity, "login.tmpl", data)
+			app.Render(w, r, http.StatusUnprocessableEntity, "login.tmpl", data)
 			return
 		}
 
 		id, err := app.Users.Authenticate(form.Email, form.Password)
 		if err != nil {
 			if errors.Is(err, models.ErrInvalidCredentials) {
+				app.Metrics.Logins.With("failure").Inc()
 				form.AddNonFieldError("Email or password is incorrect")
 				data := app.NewTemplateData(r)
 				data.Form = form
-				app.Render(w, http.StatusUnprocessableEntity, "login.tmpl.html", data)
+				app.Render(w, r, http.StatusUnprocessableEntity, "login.tmpl.html", data)
 			} else {
-				app.ServerError(w, err)
+				app.ServerError(w, r, err)
 			}
 			return
 		}
 
 		err = app.SessionManager.RenewToken(r.Context())
 		if err != nil {
-			app.ServerError(w, err)
+			app.ServerError(w, r, err)
 			return
 		}
 
 		app.SessionManager.Put(r.Context(), "authenticatedUserID", id)
+		app.Metrics.Logins.With("success").Inc()
 
 		http.Redirect(w, r, "/snippet/create", http.StatusSeeOther)
 	}
@@ -234,7 +691,7 @@ func UserLogoutPost(app *app.Application) http.HandlerFunc {
 
 		err := app.SessionManager.RenewToken(r.Context())
 		if err != nil {
-			app.ServerError(w, err)
+			app.ServerError(w, r, err)
 			return
 		}
 
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/YelzhanWeb/snippetbox/internal/app"
+	"github.com/YelzhanWeb/snippetbox/internal/models"
+)
+
+func TestValidateExpiry(t *testing.T) {
+	const day = 24 * time.Hour
+
+	now := time.Now().UTC()
+	existing := &models.Snippet{Expires: now.Add(100 * day).Truncate(time.Second)}
+	forever := &models.Snippet{Expires: models.Never}
+
+	tests := []struct {
+		name      string
+		expires   string
+		expiresAt string
+		existing  *models.Snippet
+		never     bool
+		// want is the expiry time, or its lifetime from now if it is a
+		// duration. Invalid expiries have an errorKey instead.
+		want     any
+		errorKey string
+	}{
+		{name: "minutes", expires: "10m", want: 10 * time.Minute},
+		{name: "days", expires: "30d", want: 30 * day},
+		{name: "weeks", expires: "2w", want: 14 * day},
+		{name: "bare number of days", expires: "7", want: 7 * day},
+		{name: "the maximum", expires: "60d", want: 60 * day},
+		{name: "over the maximum", expires: "61d", errorKey: "expires"},
+		{name: "invalid lifetime", expires: "soon", errorKey: "expires"},
+		{name: "zero lifetime", expires: "0d", errorKey: "expires"},
+		{name: "blank", errorKey: "expires"},
+
+		{name: "never", expires: "never", never: true, want: models.Never},
+		{name: "never when not allowed", expires: "never", errorKey: "expires"},
+
+		{name: "at in range", expires: "at", expiresAt: now.Add(10 * day).Format("2006-01-02T15:04:05"), want: now.Add(10 * day).Truncate(time.Second)},
+		{name: "at with a zone", expires: "at", expiresAt: now.Add(10 * day).In(time.FixedZone("", 5*60*60)).Format(time.RFC3339), want: now.Add(10 * day).Truncate(time.Second)},
+		{name: "expires_at alone", expiresAt: now.Add(day).Format("2006-01-02T15:04:05"), want: now.Add(day).Truncate(time.Second)},
+		{name: "at in the past", expires: "at", expiresAt: "2001-01-01T00:00", errorKey: "expires_at"},
+		{name: "at over the maximum", expires: "at", expiresAt: now.Add(61 * day).Format(time.RFC3339), errorKey: "expires_at"},
+		{name: "malformed at", expires: "at", expiresAt: "tomorrow", errorKey: "expires_at"},
+		{name: "at without a time", expires: "at", errorKey: "expires_at"},
+
+		{name: "edit keeps the expiry", existing: existing, want: existing.Expires},
+		{name: "edit keeps never when not allowed", existing: forever, want: models.Never},
+		{name: "edit changes the expiry", expires: "1h", existing: existing, want: time.Hour},
+		{name: "edit to never when not allowed", expires: "never", existing: existing, errorKey: "expires"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &app.Application{MaxExpiry: 60 * day, AllowNeverExpire: tt.never}
+			form := snippetCreateForm{Expires: expiryInput(tt.expires), ExpiresAt: tt.expiresAt}
+
+			before := time.Now().UTC()
+			form.validateExpiry(app, tt.existing)
+			after := time.Now().UTC()
+
+			if tt.errorKey != "" {
+				if _, ok := form.FieldErrors[tt.errorKey]; !ok || len(form.FieldErrors) != 1 {
+					t.Errorf("got errors %v; want one for %s", form.FieldErrors, tt.errorKey)
+				}
+				return
+			}
+			if !form.Valid() {
+				t.Fatalf("got errors %v", form.FieldErrors)
+			}
+
+			switch want := tt.want.(type) {
+			case time.Duration:
+				if form.expires.Before(before.Add(want)) || form.expires.After(after.Add(want)) {
+					t.Errorf("got expiry in %s; want %s", form.expires.Sub(before), want)
+				}
+			case time.Time:
+				if !form.expires.Equal(want) {
+					t.Errorf("got expiry %s; want %s", form.expires, want)
+				}
+			}
+		})
+	}
+}
+
+func TestExpiryInputJSON(t *testing.T) {
+	tests := []struct {
+		json string
+		want expiryInput
+	}{
+		{`7`, "7"},
+		{`"30d"`, "30d"},
+		{`"never"`, "never"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.json, func(t *testing.T) {
+			var got expiryInput
+			err := json.Unmarshal([]byte(tt.json), &got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/YelzhanWeb/snippetbox/internal/app"
+)
+
+// readinessTimeout bounds each readiness check, so that a hung database
+// fails the probe rather than stalling it.
+const readinessTimeout = 2 * time.Second
+
+// Healthz reports that the process is up and serving requests.
+func Healthz(app *app.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		app.WriteJSON(w, http.StatusOK, envelope{"status": "ok"}, http.Header{
+			"Cache-Control": {"no-store"},
+		})
+	}
+}
+
+// Readyz reports whether the server can handle traffic: its storage
+// backend and session store are reachable, its templates are loaded and it
+// is not shutting down. Any failing check makes the response a 503. Only
+// the overall status is shown, since the errors can reveal details of the
+// deployment; failures are logged, and ReadyzChecks lists them.
+func Readyz(app *app.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		results := readiness(app, r)
+		for name, err := range results {
+			if err != nil {
+				app.Logger.WarnContext(r.Context(), "readiness check failed", "check", name, "error", err)
+			}
+		}
+
+		status, overall := readinessStatus(results)
+		app.WriteJSON(w, status, envelope{"status": overall}, http.Header{
+			"Cache-Control": {"no-store"},
+		})
+	}
+}
+
+// ReadyzChecks is Readyz with the result of each check listed, for the
+// admin listener.
+func ReadyzChecks(app *app.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		results := readiness(app, r)
+
+		checks := envelope{}
+		for name, err := range results {
+			if err != nil {
+				checks[name] = envelope{"status": "failing", "error": err.Error()}
+			} else {
+				checks[name] = envelope{"status": "ok"}
+			}
+		}
+
+		status, overall := readinessStatus(results)
+		app.WriteJSON(w, status, envelope{"status": overall, "checks": checks}, http.Header{
+			"Cache-Control": {"no-store"},
+		})
+	}
+}
+
+// readiness runs the readiness checks and returns the error of each, keyed
+// by name, with nil for those which pass.
+func readiness(app *app.Application, r *http.Request) map[string]error {
+	checks := map[string]func(ctx context.Context) error{
+		"sessions": func(ctx context.Context) error {
+			_, _, err := app.SessionManager.Store.Find("readyz")
+			return err
+		},
+		"templates": func(ctx context.Context) error {
+			if len(app.TemplateCache) == 0 {
+				return errors.New("no templates loaded")
+			}
+			return nil
+		},
+		"shutdown": func(ctx context.Context) error {
+			if app.ShuttingDown.Load() {
+				return errors.New("the server is shutting down")
+			}
+			return nil
+		},
+	}
+	if app.DB != nil {
+		checks["database"] = func(ctx context.Context) error {
+			return app.DB.PingContext(ctx)
+		}
+	}
+
+	results := map[string]error{}
+	for name, check := range checks {
+		results[name] = runCheck(r.Context(), check)
+	}
+	return results
+}
+
+// readinessStatus returns the response status and overall status for the
+// results of the readiness checks.
+func readinessStatus(results map[string]error) (int, string) {
+	for _, err := range results {
+		if err != nil {
+			return http.StatusServiceUnavailable, "unavailable"
+		}
+	}
+	return http.StatusOK, "ok"
+}
+
+// runCheck runs check with a deadline of readinessTimeout. Checks which
+// cannot be cancelled, such as session store lookups, are abandoned when
+// the deadline passes.
+func runCheck(ctx context.Context, check func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return errors.New("timed out")
+	}
+}
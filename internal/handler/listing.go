@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/YelzhanWeb/snippetbox/internal/app"
+	"github.com/YelzhanWeb/snippetbox/internal/models"
+	"github.com/YelzhanWeb/snippetbox/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+func SnippetList(app *app.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, v := readPageRequest(r.URL.Query())
+		if !v.Valid() {
+			app.ClientError(w, http.StatusBadRequest)
+			return
+		}
+
+		page, err := app.Snippets.List(req)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		data := app.NewTemplateData(r)
+		data.Page = page
+
+		app.Render(w, r, http.StatusOK, "snippets.tmpl.html", data)
+	}
+}
+
+func TagView(app *app.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := httprouter.ParamsFromContext(r.Context())
+		tag := strings.ToLower(params.ByName("name"))
+
+		req, v := readPageRequest(r.URL.Query())
+		if !v.Valid() {
+			app.ClientError(w, http.StatusBadRequest)
+			return
+		}
+
+		page, err := app.Snippets.ByTag(tag, req)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		data := app.NewTemplateData(r)
+		data.Tag = tag
+		data.Page = page
+
+		app.Render(w, r, http.StatusOK, "tag.tmpl.html", data)
+	}
+}
+
+func APITagList(app *app.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tags, err := app.Snippets.Tags(100)
+		if err != nil {
+			app.ServerErrorJSON(w, r, err)
+			return
+		}
+
+		app.WriteJSON(w, http.StatusOK, envelope{"tags": tags}, nil)
+	}
+}
+
+// readPageRequest reads the sort, size and cursor query string parameters
+// shared by the snippet listings. A cursor carries its own sort, so a sort
+// parameter which disagrees with it is rejected.
+func readPageRequest(query url.Values) (models.PageRequest, validator.Validator) {
+	var v validator.Validator
+
+	req := models.PageRequest{
+		Sort: query.Get("sort"),
+		Size: readInt(query, "size", 20),
+	}
+
+	if s := query.Get("cursor"); s != "" {
+		cursor, err := models.DecodeCursor(s)
+		if err != nil {
+			v.AddFieldError("cursor", "This field must be a cursor from a previous page")
+		} else {
+			v.CheckField(req.Sort == "" || req.Sort == cursor.Sort, "sort", "This field must match the cursor's sort order")
+			req.Sort = cursor.Sort
+			req.Cursor = &cursor
+		}
+	}
+
+	if req.Sort == "" {
+		req.Sort = models.SortNewest
+	}
+
+	v.CheckField(validator.PermittedValue(req.Sort, models.Sorts...), "sort", "This field must be newest, oldest or expiring")
+	v.CheckField(req.Size >= 1 && req.Size <= 100, "size", "This field must be between 1 and 100")
+
+	return req, v
+}
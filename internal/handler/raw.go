@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/YelzhanWeb/snippetbox/internal/app"
+	"github.com/YelzhanWeb/snippetbox/internal/highlight"
+	"github.com/YelzhanWeb/snippetbox/internal/models"
+)
+
+func SnippetRaw(app *app.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snippet, ok := readableSnippet(app, w, r)
+		if !ok {
+			return
+		}
+
+		serveContent(w, r, snippet)
+	}
+}
+
+func SnippetDownload(app *app.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snippet, ok := readableSnippet(app, w, r)
+		if !ok {
+			return
+		}
+
+		disposition := mime.FormatMediaType("attachment", map[string]string{"filename": downloadFilename(snippet)})
+		w.Header().Set("Content-Disposition", disposition)
+
+		serveContent(w, r, snippet)
+	}
+}
+
+// serveContent writes the snippet's content as plain text. The ETag is
+// derived from the content, so http.ServeContent can answer conditional and
+// range requests. Private and password-protected snippets are never cached,
+// and unlisted ones only by the browser.
+func serveContent(w http.ResponseWriter, r *http.Request, snippet *models.Snippet) {
+	sum := sha256.Sum256([]byte(snippet.Content))
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+
+	switch {
+	case snippet.Protected || snippet.Visibility == models.VisibilityPrivate:
+		w.Header().Set("Cache-Control", "no-store")
+	case snippet.Visibility == models.VisibilityUnlisted:
+		w.Header().Set("Cache-Control", "private, no-cache")
+	default:
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+
+	http.ServeContent(w, r, "", time.Time{}, strings.NewReader(snippet.Content))
+}
+
+// downloadFilename turns the snippet's title into a file name, adding the
+// extension for its language unless the title already ends with it.
+func downloadFilename(snippet *models.Snippet) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == '.', r == '_', r == '-':
+			return r
+		default:
+			return '-'
+		}
+	}, strings.TrimSpace(snippet.Title))
+
+	for strings.Contains(name, "--") {
+		name = strings.ReplaceAll(name, "--", "-")
+	}
+	name = strings.Trim(name, "-.")
+
+	if name == "" {
+		name = "snippet-" + snippet.Slug
+	}
+
+	ext := highlight.FileExtension(snippet.Language)
+	if !strings.HasSuffix(strings.ToLower(name), ext) {
+		name += ext
+	}
+
+	return name
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/YelzhanWeb/snippetbox/internal/app"
+	"github.com/YelzhanWeb/snippetbox/internal/validator"
+)
+
+func Search(app *app.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		q := query.Get("q")
+
+		page := readInt(query, "page", 1)
+		if page < 1 {
+			app.ClientError(w, http.StatusBadRequest)
+			return
+		}
+
+		data := app.NewTemplateData(r)
+		data.Query = q
+
+		if validator.NotBlank(q) {
+			results, err := app.Snippets.Search(q, page, 10)
+			if err != nil {
+				app.ServerError(w, r, err)
+				return
+			}
+			data.SearchResults = results
+		}
+
+		app.Render(w, r, http.StatusOK, "search.tmpl.html", data)
+	}
+}
+
+func APISearch(app *app.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		q := query.Get("q")
+		page := readInt(query, "page", 1)
+		pageSize := readInt(query, "page_size", 20)
+
+		var v validator.Validator
+		v.CheckField(validator.NotBlank(q), "q", "This field cannot be blank")
+		v.CheckField(page >= 1, "page", "This field must be a positive integer")
+		v.CheckField(pageSize >= 1 && pageSize <= 100, "page_size", "This field must be between 1 and 100")
+
+		if !v.Valid() {
+			app.FailedValidationJSON(w, v)
+			return
+		}
+
+		results, err := app.Snippets.Search(q, page, pageSize)
+		if err != nil {
+			app.ServerErrorJSON(w, r, err)
+			return
+		}
+
+		app.WriteJSON(w, http.StatusOK, envelope{
+			"results": results.Results,
+			"metadata": envelope{
+				"page":      results.Page,
+				"page_size": results.PageSize,
+				"total":     results.Total,
+			},
+		}, nil)
+	}
+}
+
+// readInt returns the integer value of the query string parameter key, or
+// def if the parameter is missing. Unparseable values are returned as -1 so
+// that callers' range checks reject them.
+func readInt(query url.Values, key string, def int) int {
+	s := query.Get(key)
+	if s == "" {
+		return def
+	}
+
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return -1
+	}
+
+	return i
+}
@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/YelzhanWeb/snippetbox/internal/app"
+	"github.com/YelzhanWeb/snippetbox/internal/models"
+	"github.com/YelzhanWeb/snippetbox/internal/validator"
+)
+
+type snippetUnlockForm struct {
+	Password            string `form:"password"`
+	validator.Validator `form:"-"`
+}
+
+func SnippetUnlockPost(app *app.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snippet, ok := routeSnippet(app, w, r)
+		if !ok {
+			return
+		}
+
+		if unlocked(app, r, snippet) {
+			http.Redirect(w, r, "/snippet/view/"+snippet.Slug, http.StatusSeeOther)
+			return
+		}
+
+		var form snippetUnlockForm
+
+		err := app.DecodePostForm(r, &form)
+		if err != nil {
+			app.ClientError(w, http.StatusBadRequest)
+			return
+		}
+
+		form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+
+		status := http.StatusUnprocessableEntity
+
+		if form.Valid() {
+			ok, retryAfter, err := tryPassword(app, r, snippet, form.Password)
+			switch {
+			case err != nil:
+				app.ServerError(w, r, err)
+				return
+			case retryAfter > 0:
+				setRetryAfter(w, retryAfter)
+				status = http.StatusTooManyRequests
+				form.AddNonFieldError("Too many incorrect passwords. Please try again later.")
+			case !ok:
+				form.AddFieldError("password", "Password is incorrect")
+			default:
+				app.SessionManager.Put(r.Context(), unlockedKey(snippet), true)
+				http.Redirect(w, r, "/snippet/view/"+snippet.Slug, http.StatusSeeOther)
+				return
+			}
+		}
+
+		data := app.NewTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+
+		w.Header().Set("Cache-Control", "no-store")
+		app.Render(w, r, status, "unlock.tmpl.html", data)
+	}
+}
+
+// apiUnlockedSnippet is apiRouteSnippet for snippets which may be password
+// protected. API clients without an unlocked session send the password in
+// the X-Snippet-Password header.
+func apiUnlockedSnippet(app *app.Application, w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, ok := apiRouteSnippet(app, w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if unlocked(app, r, snippet) {
+		return snippet, true
+	}
+
+	password := r.Header.Get("X-Snippet-Password")
+	if password == "" {
+		app.ClientErrorMessageJSON(w, http.StatusForbidden, "this snippet is password protected; send its password in the X-Snippet-Password header")
+		return nil, false
+	}
+
+	ok, retryAfter, err := tryPassword(app, r, snippet, password)
+	switch {
+	case err != nil:
+		app.ServerErrorJSON(w, r, err)
+		return nil, false
+	case retryAfter > 0:
+		setRetryAfter(w, retryAfter)
+		app.ClientErrorMessageJSON(w, http.StatusTooManyRequests, "too many incorrect passwords, please try again later")
+		return nil, false
+	case !ok:
+		app.ClientErrorMessageJSON(w, http.StatusForbidden, "the snippet password is incorrect")
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// unlocked reports whether the requesting user may see the snippet's
+// content: it isn't password protected, they own it, or they've entered its
+// password during this session.
+func unlocked(app *app.Application, r *http.Request, snippet *models.Snippet) bool {
+	if !snippet.Protected || snippet.UserID == app.AuthenticatedUserID(r) {
+		return true
+	}
+
+	return app.SessionManager.GetBool(r.Context(), unlockedKey(snippet))
+}
+
+func unlockedKey(snippet *models.Snippet) string {
+	return fmt.Sprintf("unlockedSnippet:%s", snippet.Slug)
+}
+
+// tryPassword checks a password for a protected snippet. Failed attempts are
+// limited both per snippet and per client, so that neither one client nor
+// many can guess a snippet's password; once either limit is reached,
+// retryAfter is how long until attempts are allowed again.
+func tryPassword(app *app.Application, r *http.Request, snippet *models.Snippet, password string) (ok bool, retryAfter time.Duration, err error) {
+	snippetKey := snippet.Slug
+	clientKey := app.ClientIP(r)
+
+	// Attempts are reserved before the password is checked, so that
+	// concurrent guesses count against the limits straight away, and given
+	// back if they turn out not to be failures.
+	if allowed, wait := app.SnippetUnlocks.Reserve(snippetKey); !allowed {
+		return false, wait, nil
+	}
+	if allowed, wait := app.ClientUnlocks.Reserve(clientKey); !allowed {
+		app.SnippetUnlocks.Refund(snippetKey)
+		return false, wait, nil
+	}
+
+	ok, err = snippet.MatchesPassword(password)
+	if err != nil || ok {
+		app.SnippetUnlocks.Refund(snippetKey)
+		app.ClientUnlocks.Refund(clientKey)
+		return ok, 0, err
+	}
+
+	return false, 0, nil
+}
+
+func setRetryAfter(w http.ResponseWriter, d time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(d.Seconds()))))
+}
@@ -0,0 +1,40 @@
+//go:build ignore
+
+// gencss writes the stylesheet for highlighted snippets. It is run by go
+// generate; to change the theme, run it with any chroma style name:
+//
+//	go run gencss.go -style monokai -o ../../ui/static/css/highlight.css
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+func main() {
+	style := flag.String("style", "github", "Chroma style name")
+	out := flag.String("o", "highlight.css", "Output file")
+	flag.Parse()
+
+	s := styles.Get(*style)
+	if s == styles.Fallback && *style != "swapoff" {
+		log.Fatalf("unknown style %q", *style)
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	formatter := chromahtml.New(chromahtml.WithClasses(true))
+
+	err = formatter.WriteCSS(f, s)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,114 @@
+// Package highlight renders snippet content as syntax-highlighted HTML. The
+// markup is styled with CSS classes rather than inline styles, so that it is
+// allowed by the Content-Security-Policy. The classes are styled by
+// ui/static/css/highlight.css, which is generated from a chroma style.
+package highlight
+
+//go:generate go run gencss.go -style github -o ../../ui/static/css/highlight.css
+
+import (
+	"html/template"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// PlainText is the language of snippets which aren't highlighted.
+const PlainText = "text"
+
+type Language struct {
+	ID        string
+	Name      string
+	Extension string
+	lexer     string
+}
+
+// Languages lists the languages that snippets can be highlighted as.
+var Languages = []Language{
+	{ID: PlainText, Name: "Plain text", Extension: ".txt", lexer: "plaintext"},
+	{ID: "bash", Name: "Shell", Extension: ".sh", lexer: "bash"},
+	{ID: "c", Name: "C", Extension: ".c", lexer: "c"},
+	{ID: "css", Name: "CSS", Extension: ".css", lexer: "css"},
+	{ID: "dockerfile", Name: "Dockerfile", Extension: "", lexer: "docker"},
+	{ID: "go", Name: "Go", Extension: ".go", lexer: "go"},
+	{ID: "html", Name: "HTML", Extension: ".html", lexer: "html"},
+	{ID: "java", Name: "Java", Extension: ".java", lexer: "java"},
+	{ID: "javascript", Name: "JavaScript", Extension: ".js", lexer: "javascript"},
+	{ID: "json", Name: "JSON", Extension: ".json", lexer: "json"},
+	{ID: "markdown", Name: "Markdown", Extension: ".md", lexer: "markdown"},
+	{ID: "nginx", Name: "Nginx", Extension: ".conf", lexer: "nginx"},
+	{ID: "python", Name: "Python", Extension: ".py", lexer: "python"},
+	{ID: "rust", Name: "Rust", Extension: ".rs", lexer: "rust"},
+	{ID: "sql", Name: "SQL", Extension: ".sql", lexer: "sql"},
+	{ID: "typescript", Name: "TypeScript", Extension: ".ts", lexer: "typescript"},
+	{ID: "yaml", Name: "YAML", Extension: ".yaml", lexer: "yaml"},
+}
+
+var formatter = chromahtml.New(chromahtml.WithClasses(true), chromahtml.PreventSurroundingPre(true))
+
+func find(id string) (Language, bool) {
+	for _, l := range Languages {
+		if l.ID == id {
+			return l, true
+		}
+	}
+	return Language{}, false
+}
+
+// Supported reports whether id is one of the Languages.
+func Supported(id string) bool {
+	_, ok := find(id)
+	return ok
+}
+
+// Name returns the display name of the language, or "Plain text" for unknown
+// languages.
+func Name(id string) string {
+	if l, ok := find(id); ok {
+		return l.Name
+	}
+	return Languages[0].Name
+}
+
+// FileExtension returns the file name extension, including the dot, used for
+// snippets in the language. It is empty for languages, such as Dockerfile,
+// whose files have no extension.
+func FileExtension(id string) string {
+	if l, ok := find(id); ok {
+		return l.Extension
+	}
+	return Languages[0].Extension
+}
+
+// HTML highlights content as the given language. The result is meant to be
+// placed inside <pre class='chroma'><code>. Unknown languages are rendered as
+// plain text.
+func HTML(language, content string) (template.HTML, error) {
+	l, ok := find(language)
+	if !ok {
+		l = Languages[0]
+	}
+
+	lexer := lexers.Get(l.lexer)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, content)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+
+	// The style only affects inline styles, which WithClasses turns off.
+	err = formatter.Format(&b, styles.Fallback, iterator)
+	if err != nil {
+		return "", err
+	}
+
+	return template.HTML(b.String()), nil
+}
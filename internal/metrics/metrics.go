@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"database/sql"
+)
+
+// Metrics holds the server's metrics.
+type Metrics struct {
+	Registry
+
+	Requests        *CounterVec   // labelled method, route and status
+	RequestDuration *HistogramVec // labelled method, route and status
+	RenderDuration  *HistogramVec // labelled page
+	Panics          *Counter
+	Logins          *CounterVec // labelled result: success or failure
+	SnippetsCreated *Counter
+}
+
+// New registers the server's metrics, including the connection pool stats
+// of db unless it is nil.
+func New(db *sql.DB) *Metrics {
+	m := &Metrics{}
+
+	m.Requests = m.NewCounterVec("snippetbox_http_requests_total",
+		"HTTP requests handled, by route pattern.", "method", "route", "status")
+	m.RequestDuration = m.NewHistogramVec("snippetbox_http_request_duration_seconds",
+		"Time taken to handle HTTP requests, by route pattern.", DefBuckets, "method", "route", "status")
+	m.RenderDuration = m.NewHistogramVec("snippetbox_template_render_duration_seconds",
+		"Time taken to render HTML pages.", DefBuckets, "page")
+	m.Panics = m.NewCounter("snippetbox_panics_recovered_total",
+		"Panics recovered while handling requests.")
+	m.Logins = m.NewCounterVec("snippetbox_logins_total",
+		"Login attempts, by result.", "result")
+	m.SnippetsCreated = m.NewCounter("snippetbox_snippets_created_total",
+		"Snippets created through the web interface or the API.")
+
+	m.Logins.With("success")
+	m.Logins.With("failure")
+
+	if db != nil {
+		m.NewFunc(func() []Sample { return dbSamples(db.Stats()) })
+	}
+
+	return m
+}
+
+func dbSamples(s sql.DBStats) []Sample {
+	return []Sample{
+		{"snippetbox_db_max_open_connections", "Maximum number of open database connections.", "gauge", float64(s.MaxOpenConnections)},
+		{"snippetbox_db_open_connections", "Open database connections, in use or idle.", "gauge", float64(s.OpenConnections)},
+		{"snippetbox_db_in_use_connections", "Database connections in use.", "gauge", float64(s.InUse)},
+		{"snippetbox_db_idle_connections", "Idle database connections.", "gauge", float64(s.Idle)},
+		{"snippetbox_db_wait_count_total", "Times a database connection had to be waited for.", "counter", float64(s.WaitCount)},
+		{"snippetbox_db_wait_duration_seconds_total", "Time spent waiting for database connections.", "counter", s.WaitDuration.Seconds()},
+		{"snippetbox_db_max_idle_closed_total", "Connections closed because of the idle connection limit.", "counter", float64(s.MaxIdleClosed)},
+		{"snippetbox_db_max_idle_time_closed_total", "Connections closed because they were idle too long.", "counter", float64(s.MaxIdleTimeClosed)},
+		{"snippetbox_db_max_lifetime_closed_total", "Connections closed because they reached their maximum lifetime.", "counter", float64(s.MaxLifetimeClosed)},
+	}
+}
@@ -0,0 +1,281 @@
+// Package metrics collects counters and histograms and exposes them in the
+// Prometheus text exposition format, without depending on the Prometheus
+// client library.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefBuckets are the default histogram buckets, in seconds, which suit the
+// latency of typical requests.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// collector writes one or more metric families in the text format.
+type collector interface {
+	collect(w io.Writer)
+}
+
+// Registry holds the metrics to expose.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+func (reg *Registry) register(c collector) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.collectors = append(reg.collectors, c)
+}
+
+// WriteTo writes every registered metric in the text exposition format.
+func (reg *Registry) WriteTo(w io.Writer) (int64, error) {
+	reg.mu.Lock()
+	collectors := slices.Clone(reg.collectors)
+	reg.mu.Unlock()
+
+	var buf bytes.Buffer
+	for _, c := range collectors {
+		c.collect(&buf)
+	}
+	return buf.WriteTo(w)
+}
+
+// Handler serves the registered metrics to Prometheus.
+func (reg *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		reg.WriteTo(w)
+	})
+}
+
+// Counter is a value which only goes up.
+type Counter struct {
+	n atomic.Uint64
+}
+
+func (c *Counter) Inc() {
+	c.n.Add(1)
+}
+
+// Histogram counts observations in cumulative buckets.
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// vec holds one series of type T for each combination of label values.
+type vec[T any] struct {
+	name, help, kind string
+	labels           []string
+	newSeries        func() *T
+
+	mu     sync.Mutex
+	series map[string]*labelled[T]
+}
+
+type labelled[T any] struct {
+	values []string
+	series *T
+}
+
+func (v *vec[T]) with(values []string) *T {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", v.name, len(v.labels), len(values)))
+	}
+
+	key := strings.Join(values, "\xff")
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	s, ok := v.series[key]
+	if !ok {
+		s = &labelled[T]{values: slices.Clone(values), series: v.newSeries()}
+		v.series[key] = s
+	}
+	return s.series
+}
+
+// sorted returns the series ordered by their label values, so that the
+// output is stable between scrapes.
+func (v *vec[T]) sorted() []*labelled[T] {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	series := make([]*labelled[T], len(keys))
+	for i, k := range keys {
+		series[i] = v.series[k]
+	}
+	return series
+}
+
+func (v *vec[T]) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, escapeHelp(v.help), v.name, v.kind)
+}
+
+// CounterVec is a family of counters partitioned by labels.
+type CounterVec struct {
+	v *vec[Counter]
+}
+
+// NewCounterVec registers a counter family with the given label names.
+func (reg *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	cv := &CounterVec{&vec[Counter]{
+		name: name, help: help, kind: "counter", labels: labels,
+		newSeries: func() *Counter { return new(Counter) },
+		series:    map[string]*labelled[Counter]{},
+	}}
+	reg.register(cv)
+	return cv
+}
+
+// NewCounter registers a counter without labels.
+func (reg *Registry) NewCounter(name, help string) *Counter {
+	return reg.NewCounterVec(name, help).With()
+}
+
+// With returns the counter for the given label values, in the order the
+// labels were declared.
+func (cv *CounterVec) With(values ...string) *Counter {
+	return cv.v.with(values)
+}
+
+func (cv *CounterVec) collect(w io.Writer) {
+	cv.v.header(w)
+	for _, s := range cv.v.sorted() {
+		fmt.Fprintf(w, "%s%s %d\n", cv.v.name, labelPairs(cv.v.labels, s.values), s.series.n.Load())
+	}
+}
+
+// HistogramVec is a family of histograms partitioned by labels.
+type HistogramVec struct {
+	v *vec[Histogram]
+}
+
+// NewHistogramVec registers a histogram family with the given upper bucket
+// bounds, in increasing order, and label names.
+func (reg *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	hv := &HistogramVec{&vec[Histogram]{
+		name: name, help: help, kind: "histogram", labels: labels,
+		newSeries: func() *Histogram {
+			return &Histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+		},
+		series: map[string]*labelled[Histogram]{},
+	}}
+	reg.register(hv)
+	return hv
+}
+
+// With returns the histogram for the given label values, in the order the
+// labels were declared.
+func (hv *HistogramVec) With(values ...string) *Histogram {
+	return hv.v.with(values)
+}
+
+func (hv *HistogramVec) collect(w io.Writer) {
+	hv.v.header(w)
+
+	labels := append(slices.Clone(hv.v.labels), "le")
+	for _, s := range hv.v.sorted() {
+		h := s.series
+		h.mu.Lock()
+		for i, upper := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", hv.v.name, labelPairs(labels, append(slices.Clone(s.values), formatFloat(upper))), h.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", hv.v.name, labelPairs(labels, append(slices.Clone(s.values), "+Inf")), h.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", hv.v.name, labelPairs(hv.v.labels, s.values), formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", hv.v.name, labelPairs(hv.v.labels, s.values), h.count)
+		h.mu.Unlock()
+	}
+}
+
+// Sample is one value reported by a Func collector.
+type Sample struct {
+	Name  string
+	Help  string
+	Kind  string // "gauge" or "counter"
+	Value float64
+}
+
+type funcCollector func() []Sample
+
+func (f funcCollector) collect(w io.Writer) {
+	for _, s := range f() {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", s.Name, escapeHelp(s.Help), s.Name, s.Kind, s.Name, formatFloat(s.Value))
+	}
+}
+
+// NewFunc registers a function which reports unlabelled samples read at
+// scrape time, for values kept elsewhere such as connection pool stats.
+func (reg *Registry) NewFunc(f func() []Sample) {
+	reg.register(funcCollector(f))
+}
+
+func labelPairs(labels, values []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, l := range labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(l)
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T, reg *Registry) string {
+	t.Helper()
+
+	var b strings.Builder
+	_, err := reg.WriteTo(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestCounterVec(t *testing.T) {
+	var reg Registry
+	cv := reg.NewCounterVec("test_total", "Test counter.", "method", "route")
+
+	cv.With("POST", "/b").Inc()
+	cv.With("GET", "/a").Inc()
+	cv.With("GET", "/a").Inc()
+
+	want := `# HELP test_total Test counter.
+# TYPE test_total counter
+test_total{method="GET",route="/a"} 2
+test_total{method="POST",route="/b"} 1
+`
+	if got := scrape(t, &reg); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCounterWithoutLabels(t *testing.T) {
+	var reg Registry
+	c := reg.NewCounter("test_total", "Test counter.")
+	c.Inc()
+
+	want := `# HELP test_total Test counter.
+# TYPE test_total counter
+test_total 1
+`
+	if got := scrape(t, &reg); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestHistogramVec(t *testing.T) {
+	var reg Registry
+	hv := reg.NewHistogramVec("test_seconds", "Test histogram.", []float64{0.1, 1}, "page")
+
+	h := hv.With("home")
+	h.Observe(0.05)
+	h.Observe(0.1)
+	h.Observe(0.5)
+	h.Observe(3)
+
+	want := `# HELP test_seconds Test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{page="home",le="0.1"} 2
+test_seconds_bucket{page="home",le="1"} 3
+test_seconds_bucket{page="home",le="+Inf"} 4
+test_seconds_sum{page="home"} 3.65
+test_seconds_count{page="home"} 4
+`
+	if got := scrape(t, &reg); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestEscaping(t *testing.T) {
+	var reg Registry
+	cv := reg.NewCounterVec("test_total", "Help with a \\ backslash\nand a newline.", "value")
+	cv.With("quote \" backslash \\ newline \n").Inc()
+
+	want := `# HELP test_total Help with a \\ backslash\nand a newline.
+# TYPE test_total counter
+test_total{value="quote \" backslash \\ newline \n"} 1
+`
+	if got := scrape(t, &reg); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFunc(t *testing.T) {
+	var reg Registry
+	reg.NewFunc(func() []Sample {
+		return []Sample{{"test_connections", "Test gauge.", "gauge", 3}}
+	})
+
+	want := `# HELP test_connections Test gauge.
+# TYPE test_connections gauge
+test_connections 3
+`
+	if got := scrape(t, &reg); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWrongLabelCount(t *testing.T) {
+	var reg Registry
+	cv := reg.NewCounterVec("test_total", "Test counter.", "method")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("With with too many values didn't panic")
+		}
+	}()
+	cv.With("GET", "extra")
+}
@@ -0,0 +1,310 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/YelzhanWeb/snippetbox/migrations"
+)
+
+var ErrNoMigrations = errors.New("migrate: no migrations have been applied")
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+
+	// Step, if set, is run after the Up script in the same transaction,
+	// for changes that can't be made in SQL.
+	Step func(*sql.Tx) error
+}
+
+// String returns the migration's file name prefix, such as
+// "0001_create_users".
+func (mg Migration) String() string {
+	return fmt.Sprintf("%04d_%s", mg.Version, mg.Name)
+}
+
+type Status struct {
+	Migration
+	Applied time.Time
+}
+
+// IsApplied reports whether the migration has been applied to the database.
+func (s Status) IsApplied() bool {
+	return !s.Applied.IsZero()
+}
+
+// Migrator applies the embedded migrations for one SQL dialect, recording
+// every applied version in the schema_migrations table.
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration
+}
+
+// New returns a Migrator for the given dialect, either "mysql" or "sqlite".
+func New(db *sql.DB, dialect string) (*Migrator, error) {
+	dir, err := fs.Sub(migrations.Files, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	ms, err := load(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(ms) == 0 {
+		return nil, fmt.Errorf("migrate: no migrations found for dialect %q", dialect)
+	}
+
+	return &Migrator{DB: db, Migrations: ms}, nil
+}
+
+// load reads NNNN_name.up.sql and NNNN_name.down.sql pairs from dir and
+// returns them ordered by version.
+func load(dir fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(dir, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+
+	for _, file := range files {
+		base, direction, ok := strings.Cut(strings.TrimSuffix(file, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("migrate: badly named migration file %q", file)
+		}
+
+		prefix, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version < 1 {
+			return nil, fmt.Errorf("migrate: badly named migration file %q", file)
+		}
+
+		b, err := fs.ReadFile(dir, file)
+		if err != nil {
+			return nil, err
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migrate: version %d is used by both %q and %q", version, m.Name, name)
+		}
+
+		if direction == "up" {
+			m.Up = string(b)
+		} else {
+			m.Down = string(b)
+		}
+	}
+
+	ms := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migrate: migration %s needs both an up and a down file", m)
+		}
+		m.Step = steps[m.Version]
+		ms = append(ms, *m)
+	}
+
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Version < ms[j].Version })
+
+	return ms, nil
+}
+
+func (m *Migrator) init() error {
+	stmt := `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER NOT NULL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	applied DATETIME NOT NULL
+	)`
+
+	_, err := m.DB.Exec(stmt)
+	return err
+}
+
+// applied returns the time at which each applied version was applied.
+func (m *Migrator) applied() (map[int]time.Time, error) {
+	if err := m.init(); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.DB.Query(`SELECT version, applied FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	versions := map[int]time.Time{}
+
+	for rows.Next() {
+		var version int
+		var applied time.Time
+
+		err = rows.Scan(&version, &applied)
+		if err != nil {
+			return nil, err
+		}
+
+		versions[version] = applied
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+// Status lists every known migration and when, if ever, it was applied.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(m.Migrations))
+	for i, mg := range m.Migrations {
+		statuses[i] = Status{Migration: mg, Applied: applied[mg.Version]}
+	}
+
+	return statuses, nil
+}
+
+// Up applies every pending migration in version order and returns the ones
+// it applied.
+func (m *Migrator) Up() ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+
+	for _, mg := range m.Migrations {
+		if _, ok := applied[mg.Version]; ok {
+			continue
+		}
+
+		err = m.run(mg.Up, func(tx *sql.Tx) error {
+			if mg.Step != nil {
+				if err := mg.Step(tx); err != nil {
+					return err
+				}
+			}
+
+			stmt := `INSERT INTO schema_migrations (version, name, applied) VALUES (?, ?, ?)`
+			_, err := tx.Exec(stmt, mg.Version, mg.Name, time.Now().UTC())
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("migrate: applying %s: %w", mg, err)
+		}
+
+		done = append(done, mg)
+	}
+
+	return done, nil
+}
+
+// Down reverts the most recently applied migration and returns it. It
+// returns ErrNoMigrations if nothing has been applied.
+func (m *Migrator) Down() (Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return Migration{}, err
+	}
+
+	for i := len(m.Migrations) - 1; i >= 0; i-- {
+		mg := m.Migrations[i]
+		if _, ok := applied[mg.Version]; !ok {
+			continue
+		}
+
+		err = m.run(mg.Down, func(tx *sql.Tx) error {
+			_, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = ?`, mg.Version)
+			return err
+		})
+		if err != nil {
+			return Migration{}, fmt.Errorf("migrate: reverting %s: %w", mg, err)
+		}
+
+		return mg, nil
+	}
+
+	return Migration{}, ErrNoMigrations
+}
+
+// run executes each statement in script and then calls record, inside a single
+// transaction. MySQL commits DDL statements implicitly, so there a failed
+// migration may need to be repaired by hand.
+func (m *Migrator) run(script string, record func(*sql.Tx) error) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range statements(script) {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	if err := record(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// statements splits a migration script on semicolons, keeping the
+// statements in the body of a CREATE TRIGGER together up to its END.
+// Migrations must not contain semicolons inside string literals or comments.
+func statements(script string) []string {
+	var stmts []string
+	var pending strings.Builder
+
+	for _, part := range strings.Split(script, ";") {
+		pending.WriteString(part)
+		stmt := strings.TrimSpace(pending.String())
+
+		if isTrigger(stmt) && !endsTrigger(stmt) {
+			pending.WriteString(";")
+			continue
+		}
+
+		pending.Reset()
+		if stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+
+	if stmt := strings.TrimSpace(pending.String()); stmt != "" {
+		stmts = append(stmts, stmt)
+	}
+
+	return stmts
+}
+
+func isTrigger(stmt string) bool {
+	fields := strings.Fields(strings.ToUpper(stmt))
+	return len(fields) >= 2 && fields[0] == "CREATE" && fields[1] == "TRIGGER"
+}
+
+func endsTrigger(stmt string) bool {
+	fields := strings.Fields(strings.ToUpper(stmt))
+	return len(fields) > 0 && fields[len(fields)-1] == "END"
+}
@@ -0,0 +1,126 @@
+package migrate
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/YelzhanWeb/snippetbox/internal/models"
+	storage "github.com/YelzhanWeb/snippetbox/pkg/db"
+)
+
+func TestStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{"empty", "  \n", nil},
+		{"one", "CREATE TABLE t (id INTEGER);\n", []string{"CREATE TABLE t (id INTEGER)"}},
+		{"no trailing semicolon", "DROP TABLE a;\nDROP TABLE b", []string{"DROP TABLE a", "DROP TABLE b"}},
+		{
+			"trigger",
+			"CREATE TABLE t (id INTEGER);\n" +
+				"CREATE TRIGGER t_ai AFTER INSERT ON t BEGIN\n" +
+				"    INSERT INTO log VALUES (new.id);\n" +
+				"    DELETE FROM log WHERE id < new.id - 10;\n" +
+				"END;\n" +
+				"DROP TABLE u;\n",
+			[]string{
+				"CREATE TABLE t (id INTEGER)",
+				"CREATE TRIGGER t_ai AFTER INSERT ON t BEGIN\n" +
+					"    INSERT INTO log VALUES (new.id);\n" +
+					"    DELETE FROM log WHERE id < new.id - 10;\n" +
+					"END",
+				"DROP TABLE u",
+			},
+		},
+		{
+			"lower-case trigger",
+			"create trigger t_ad after delete on t begin delete from log where id = old.id; end;",
+			[]string{"create trigger t_ad after delete on t begin delete from log where id = old.id; end"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := statements(tt.script); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssignSlugs(t *testing.T) {
+	db, err := storage.InitDB("sqlite3", "file:"+filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m, err := New(db, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Apply the migrations before slugs were added, then add some snippets
+	// for migration 9 to give slugs to.
+	all := m.Migrations
+	i := slices.IndexFunc(all, func(mg Migration) bool { return mg.Version == 9 })
+	m.Migrations = all[:i]
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	_, err = db.Exec(`INSERT INTO users (name, email, hashed_password, created) VALUES ('Alice', 'alice@example.com', '', ?)`, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const count = 50
+	for range count {
+		_, err = db.Exec(`INSERT INTO snippets (title, content, created, expires, user_id) VALUES ('Title', 'Content', ?, ?, 1)`, now, now.Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m.Migrations = all
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.Query(`SELECT slug FROM snippets`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	seen := map[string]bool{}
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
+			t.Fatal(err)
+		}
+		if !validSlug(slug) {
+			t.Errorf("snippet has slug %q; want %d base62 characters including a letter", slug, models.SlugLength)
+		}
+		seen[slug] = true
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(seen) != count {
+		t.Errorf("got %d distinct slugs; want %d", len(seen), count)
+	}
+}
+
+func validSlug(slug string) bool {
+	const alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	return len(slug) == models.SlugLength &&
+		strings.Trim(slug, alphabet) == "" &&
+		strings.Trim(slug, "0123456789") != ""
+}
@@ -0,0 +1,56 @@
+package migrate
+
+import (
+	"database/sql"
+
+	"github.com/YelzhanWeb/snippetbox/internal/models"
+)
+
+// steps are the Go steps of migrations, by version.
+var steps = map[int]func(*sql.Tx) error{
+	9: assignSlugs,
+}
+
+// assignSlugs replaces the placeholder slugs given to existing snippets by
+// migration 9 with random ones from models.NewSlug.
+func assignSlugs(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT id FROM snippets WHERE slug LIKE '-%'`)
+	if err != nil {
+		return err
+	}
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	taken := map[string]bool{}
+
+	for _, id := range ids {
+		slug, err := models.NewSlug()
+		for err == nil && taken[slug] {
+			slug, err = models.NewSlug()
+		}
+		if err != nil {
+			return err
+		}
+		taken[slug] = true
+
+		_, err = tx.Exec(`UPDATE snippets SET slug = ? WHERE id = ?`, slug, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1,9 +1,31 @@
 package models
 
-import "errors"
+import (
+	"errors"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/mattn/go-sqlite3"
+)
 
 var (
 	ErrNoRecord           = errors.New("models: no matching record found")
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 	ErrDuplicateEmail     = errors.New("models: duplicate email")
 )
+
+// isUniqueViolation reports whether err is a unique constraint violation on
+// the named MySQL key or, for SQLite, on the named table.column.
+func isUniqueViolation(err error, mysqlKey, sqliteColumn string) bool {
+	var mySQLError *mysql.MySQLError
+	if errors.As(err, &mySQLError) {
+		return mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, mysqlKey)
+	}
+
+	var sqliteError sqlite3.Error
+	if errors.As(err, &sqliteError) {
+		return sqliteError.ExtendedCode == sqlite3.ErrConstraintUnique && strings.Contains(sqliteError.Error(), sqliteColumn)
+	}
+
+	return false
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Never is the expiry time of snippets which never expire. It is stored as
+// a real time rather than NULL so that queries and sorts can keep comparing
+// expiry times directly.
+var Never = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+var ErrInvalidDuration = errors.New("models: invalid duration")
+
+var durationUnits = map[byte]time.Duration{
+	'm': time.Minute,
+	'h': time.Hour,
+	'd': 24 * time.Hour,
+	'w': 7 * 24 * time.Hour,
+}
+
+// ParseDuration parses a snippet lifetime such as "10m", "1h", "30d" or
+// "2w". A bare number is a number of days, which is how lifetimes were given
+// before other units were supported.
+func ParseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, ErrInvalidDuration
+	}
+
+	unit := 24 * time.Hour
+	if u, ok := durationUnits[s[len(s)-1]]; ok {
+		unit = u
+		s = s[:len(s)-1]
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || strings.HasPrefix(s, "+") {
+		return 0, ErrInvalidDuration
+	}
+
+	// A time.Duration can only hold about 290 years.
+	if time.Duration(n) > math.MaxInt64/unit {
+		return 0, ErrInvalidDuration
+	}
+
+	return time.Duration(n) * unit, nil
+}
+
+// FormatDuration formats d for ParseDuration in days, hours or minutes,
+// whichever is the largest unit that represents it exactly, after rounding
+// down to whole minutes.
+func FormatDuration(d time.Duration) string {
+	d = d.Truncate(time.Minute)
+
+	switch {
+	case d%(24*time.Hour) == 0:
+		return strconv.FormatInt(int64(d/(24*time.Hour)), 10) + "d"
+	case d%time.Hour == 0:
+		return strconv.FormatInt(int64(d/time.Hour), 10) + "h"
+	default:
+		return strconv.FormatInt(int64(d/time.Minute), 10) + "m"
+	}
+}
@@ -0,0 +1,76 @@
+package models_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/YelzhanWeb/snippetbox/internal/models"
+)
+
+func TestParseDuration(t *testing.T) {
+	const day = 24 * time.Hour
+
+	tests := []struct {
+		input string
+		want  time.Duration
+	}{
+		{"10m", 10 * time.Minute},
+		{"1h", time.Hour},
+		{"36h", 36 * time.Hour},
+		{"30d", 30 * day},
+		{"2w", 14 * day},
+		{"7", 7 * day},
+		{"0365d", 365 * day},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := models.ParseDuration(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s; want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDurationInvalid(t *testing.T) {
+	for _, input := range []string{"", "d", "0", "0d", "-1d", "+1d", "1.5h", "1y", "1D", "10s", " 1d", "1d ", "never", "9999999999w"} {
+		t.Run(input, func(t *testing.T) {
+			_, err := models.ParseDuration(input)
+			if !errors.Is(err, models.ErrInvalidDuration) {
+				t.Errorf("got %v; want ErrInvalidDuration", err)
+			}
+		})
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{365 * 24 * time.Hour, "365d"},
+		{48 * time.Hour, "2d"},
+		{36 * time.Hour, "36h"},
+		{90 * time.Minute, "90m"},
+		{90*time.Minute + 59*time.Second, "90m"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			got := models.FormatDuration(tt.d)
+			if got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+
+			parsed, err := models.ParseDuration(got)
+			if err != nil || parsed != tt.d.Truncate(time.Minute) {
+				t.Errorf("parsing %q gave %s, %v; want %s", got, parsed, err, tt.d.Truncate(time.Minute))
+			}
+		})
+	}
+}
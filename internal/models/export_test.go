@@ -0,0 +1,12 @@
+package models
+
+import "testing"
+
+const MaxSlugAttempts = maxSlugAttempts
+
+// SetNewSlug makes Insert use f to generate slugs until the test ends.
+func SetNewSlug(t *testing.T, f func() (string, error)) {
+	old := newSlug
+	newSlug = f
+	t.Cleanup(func() { newSlug = old })
+}
@@ -0,0 +1,322 @@
+package memory
+
+import (
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/YelzhanWeb/snippetbox/internal/models"
+	"github.com/YelzhanWeb/snippetbox/internal/search"
+)
+
+// revision is an archived version of a snippet, stamped with the time at
+// which it was replaced.
+type revision struct {
+	title    string
+	content  string
+	replaced time.Time
+}
+
+// SnippetModel is an in-memory models.SnippetStore. Users is consulted for
+// author names.
+type SnippetModel struct {
+	Users      *UserModel
+	BcryptCost int
+
+	mu        sync.RWMutex
+	snippets  map[int]*models.Snippet
+	slugs     map[string]int
+	revisions map[int][]revision
+	nextID    int
+}
+
+func (m *SnippetModel) Insert(fields models.SnippetFields, userID int) (string, error) {
+	var hashedPassword []byte
+	if fields.Password != "" {
+		hash, err := models.HashPassword(fields.Password, m.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		hashedPassword = hash
+	}
+
+	now := time.Now().UTC()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.snippets == nil {
+		m.snippets = make(map[int]*models.Snippet)
+		m.slugs = make(map[string]int)
+		m.revisions = make(map[int][]revision)
+	}
+
+	slug, err := models.NewSlug()
+	for err == nil && m.slugs[slug] != 0 {
+		slug, err = models.NewSlug()
+	}
+	if err != nil {
+		return "", err
+	}
+
+	m.nextID++
+	m.slugs[slug] = m.nextID
+	m.snippets[m.nextID] = &models.Snippet{
+		ID:         m.nextID,
+		Slug:       slug,
+		Title:      fields.Title,
+		Content:    fields.Content,
+		Language:   fields.Language,
+		Visibility: fields.Visibility,
+		MaxViews:   fields.MaxViews,
+		Protected:  hashedPassword != nil,
+		Encrypted:  fields.Encrypted,
+		Created:    now,
+		Expires:    fields.Expires.UTC(),
+		UserID:     userID,
+		Tags:       sortedTags(fields.Tags),
+
+		HashedPassword: hashedPassword,
+	}
+
+	return slug, nil
+}
+
+func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.get(id)
+}
+
+func (m *SnippetModel) GetBySlug(slug string) (*models.Snippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.get(m.slugs[slug])
+}
+
+// get returns a copy of a non-expired snippet. The caller must hold the
+// read lock, as Update and Reveal change stored snippets in place.
+func (m *SnippetModel) get(id int) (*models.Snippet, error) {
+	s, ok := m.snippets[id]
+	if !ok || !s.Expires.After(time.Now().UTC()) {
+		return nil, models.ErrNoRecord
+	}
+
+	return m.copy(s), nil
+}
+
+func (m *SnippetModel) Update(id int, fields models.SnippetFields) error {
+	now := time.Now().UTC()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.snippets[id]
+	if !ok {
+		return nil
+	}
+
+	if s.Title != fields.Title || s.Content != fields.Content {
+		m.revisions[id] = append(m.revisions[id], revision{
+			title:    s.Title,
+			content:  s.Content,
+			replaced: now,
+		})
+	}
+
+	s.Title = fields.Title
+	s.Content = fields.Content
+	s.Language = fields.Language
+	s.Visibility = fields.Visibility
+	s.MaxViews = fields.MaxViews
+	s.Expires = fields.Expires.UTC()
+	s.Tags = sortedTags(fields.Tags)
+
+	return nil
+}
+
+func (m *SnippetModel) Delete(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.delete(id)
+
+	return nil
+}
+
+func (m *SnippetModel) Reveal(id int) (*models.Snippet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.snippets[id]
+	if !ok || !s.Expires.After(time.Now().UTC()) || s.MaxViews == 0 || s.Views >= s.MaxViews {
+		return nil, models.ErrNoRecord
+	}
+
+	s.Views++
+	revealed := m.copy(s)
+
+	if s.Views >= s.MaxViews {
+		m.delete(id)
+	}
+
+	return revealed, nil
+}
+
+// DeleteExpired deletes up to limit expired snippets, oldest expiry first,
+// along with their revisions, and returns how many it deleted.
+func (m *SnippetModel) DeleteExpired(limit int) (int, error) {
+	now := time.Now().UTC()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var expired []*models.Snippet
+	for _, s := range m.snippets {
+		if !s.Expires.After(now) {
+			expired = append(expired, s)
+		}
+	}
+
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].Expires.Before(expired[j].Expires)
+	})
+
+	if len(expired) > limit {
+		expired = expired[:limit]
+	}
+
+	for _, s := range expired {
+		m.delete(s.ID)
+	}
+
+	return len(expired), nil
+}
+
+// delete removes a snippet. The caller must hold the write lock.
+func (m *SnippetModel) delete(id int) {
+	if s, ok := m.snippets[id]; ok {
+		delete(m.slugs, s.Slug)
+	}
+	delete(m.snippets, id)
+	delete(m.revisions, id)
+}
+
+func (m *SnippetModel) History(current *models.Snippet) ([]*models.Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	revisions := []*models.Revision{}
+	written := current.Created
+
+	for _, r := range m.revisions[current.ID] {
+		revisions = append(revisions, &models.Revision{
+			Version: len(revisions) + 1,
+			Title:   r.title,
+			Content: r.content,
+			Created: written,
+		})
+		written = r.replaced
+	}
+
+	revisions = append(revisions, &models.Revision{
+		Version: len(revisions) + 1,
+		Title:   current.Title,
+		Content: current.Content,
+		Created: written,
+		Current: true,
+	})
+
+	return revisions, nil
+}
+
+func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
+	snippets := m.filter(public)
+	if len(snippets) > 10 {
+		snippets = snippets[:10]
+	}
+	return snippets, nil
+}
+
+func (m *SnippetModel) List(req models.PageRequest) (*models.SnippetPage, error) {
+	snippets := m.filter(public)
+	return models.ListSnippets(req, snippets), nil
+}
+
+func (m *SnippetModel) ByTag(tag string, req models.PageRequest) (*models.SnippetPage, error) {
+	snippets := m.filter(func(s *models.Snippet) bool { return public(s) && slices.Contains(s.Tags, tag) })
+	return models.ListSnippets(req, snippets), nil
+}
+
+func (m *SnippetModel) Tags(limit int) ([]*models.TagCount, error) {
+	counts := map[string]int{}
+	for _, s := range m.filter(public) {
+		for _, t := range s.Tags {
+			counts[t]++
+		}
+	}
+	return models.TagCloud(counts, limit), nil
+}
+
+func (m *SnippetModel) ByUser(userID int) ([]*models.Snippet, error) {
+	return m.filter(func(s *models.Snippet) bool { return s.UserID == userID }), nil
+}
+
+func (m *SnippetModel) Search(query string, page, pageSize int) (*models.SearchResults, error) {
+	terms := search.Terms(query)
+
+	candidates := m.filter(func(s *models.Snippet) bool {
+		if !public(s) || s.Encrypted {
+			return false
+		}
+
+		return search.Matches(terms, s.Title) || search.Matches(terms, s.Content)
+	})
+
+	return models.RankSearch(terms, candidates, page, pageSize), nil
+}
+
+// filter returns copies of the non-expired snippets matching keep, newest
+// first.
+func (m *SnippetModel) filter(keep func(*models.Snippet) bool) []*models.Snippet {
+	now := time.Now().UTC()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	snippets := []*models.Snippet{}
+	for _, s := range m.snippets {
+		if s.Expires.After(now) && keep(s) {
+			snippets = append(snippets, m.copy(s))
+		}
+	}
+
+	sort.Slice(snippets, func(i, j int) bool { return snippets[i].ID > snippets[j].ID })
+
+	return snippets
+}
+
+// public reports whether s is listed and searchable.
+func public(s *models.Snippet) bool {
+	return s.Visibility == models.VisibilityPublic
+}
+
+// copy returns a copy of s with its author filled in, so that callers can't
+// modify the stored snippet.
+func (m *SnippetModel) copy(s *models.Snippet) *models.Snippet {
+	c := *s
+	c.Author = m.Users.name(s.UserID)
+	c.Tags = slices.Clone(s.Tags)
+	return &c
+}
+
+// sortedTags returns a sorted copy of tags, matching the order in which the
+// SQL backends return them.
+func sortedTags(tags []string) []string {
+	sorted := append([]string{}, tags...)
+	sort.Strings(sorted)
+	return sorted
+}
@@ -0,0 +1,20 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/YelzhanWeb/snippetbox/internal/models/memory"
+	"github.com/YelzhanWeb/snippetbox/internal/models/storetest"
+)
+
+func TestStores(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storetest.Stores {
+		users := &memory.UserModel{BcryptCost: 4}
+
+		return storetest.Stores{
+			Snippets: &memory.SnippetModel{Users: users, BcryptCost: 4},
+			Users:    users,
+			Tokens:   &memory.TokenModel{},
+		}
+	})
+}
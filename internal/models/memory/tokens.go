@@ -0,0 +1,106 @@
+package memory
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/YelzhanWeb/snippetbox/internal/models"
+)
+
+type storedToken struct {
+	models.Token
+	hash []byte
+}
+
+// TokenModel is an in-memory models.TokenStore. The zero value is ready to
+// use.
+type TokenModel struct {
+	mu     sync.RWMutex
+	tokens map[int]*storedToken
+	nextID int
+}
+
+func (m *TokenModel) Insert(userID int, name, scope string, days int) (string, error) {
+	plaintext, err := models.GenerateToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+
+	t := &storedToken{
+		Token: models.Token{
+			UserID:  userID,
+			Name:    name,
+			Scope:   scope,
+			Created: now,
+		},
+		hash: models.HashToken(plaintext),
+	}
+	if days > 0 {
+		t.Expires = now.AddDate(0, 0, days)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.tokens == nil {
+		m.tokens = make(map[int]*storedToken)
+	}
+
+	m.nextID++
+	t.ID = m.nextID
+	m.tokens[t.ID] = t
+
+	return plaintext, nil
+}
+
+func (m *TokenModel) Authenticate(plaintext string) (*models.Token, error) {
+	hash := models.HashToken(plaintext)
+	now := time.Now().UTC()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, t := range m.tokens {
+		if bytes.Equal(t.hash, hash) && (t.Expires.IsZero() || t.Expires.After(now)) {
+			token := t.Token
+			return &token, nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (m *TokenModel) ForUser(userID int) ([]*models.Token, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tokens := []*models.Token{}
+	for _, t := range m.tokens {
+		if t.UserID == userID {
+			token := t.Token
+			tokens = append(tokens, &token)
+		}
+	}
+
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID > tokens[j].ID })
+
+	return tokens, nil
+}
+
+func (m *TokenModel) Delete(id, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.tokens[id]
+	if !ok || t.UserID != userID {
+		return models.ErrNoRecord
+	}
+
+	delete(m.tokens, id)
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YelzhanWeb/snippetbox/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// UserModel is an in-memory models.UserStore. The zero value is ready to
+// use.
+type UserModel struct {
+	BcryptCost int
+
+	mu     sync.RWMutex
+	users  map[int]*models.User
+	nextID int
+}
+
+func (m *UserModel) Insert(name, email, password string) error {
+	hashedPassword, err := models.HashPassword(password, m.BcryptCost)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Emails are compared case-insensitively, as MySQL's default collation
+	// does.
+	for _, u := range m.users {
+		if strings.EqualFold(u.Email, email) {
+			return models.ErrDuplicateEmail
+		}
+	}
+
+	if m.users == nil {
+		m.users = make(map[int]*models.User)
+	}
+
+	m.nextID++
+	m.users[m.nextID] = &models.User{
+		ID:             m.nextID,
+		Name:           name,
+		Email:          email,
+		HashedPassword: hashedPassword,
+		Created:        time.Now().UTC(),
+	}
+
+	return nil
+}
+
+func (m *UserModel) Authenticate(email, password string) (int, error) {
+	m.mu.RLock()
+	var user *models.User
+	for _, u := range m.users {
+		if strings.EqualFold(u.Email, email) {
+			user = u
+			break
+		}
+	}
+	m.mu.RUnlock()
+
+	if user == nil {
+		return 0, models.ErrInvalidCredentials
+	}
+
+	err := bcrypt.CompareHashAndPassword(user.HashedPassword, []byte(password))
+	if err != nil {
+		return 0, models.ErrInvalidCredentials
+	}
+
+	return user.ID, nil
+}
+
+func (m *UserModel) Exists(id int) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.users[id]
+	return ok, nil
+}
+
+func (m *UserModel) Get(id int) (*models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+
+	return &models.User{ID: u.ID, Name: u.Name, Email: u.Email, Created: u.Created}, nil
+}
+
+// name returns the user's name, or "" if there is no such user.
+func (m *UserModel) name(id int) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if u, ok := m.users[id]; ok {
+		return u.Name
+	}
+	return ""
+}
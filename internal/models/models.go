@@ -0,0 +1,41 @@
+package models
+
+// SnippetStore is implemented by every snippet storage backend.
+type SnippetStore interface {
+	Insert(fields SnippetFields, userID int) (string, error)
+	Get(id int) (*Snippet, error)
+	GetBySlug(slug string) (*Snippet, error)
+	Update(id int, fields SnippetFields) error
+	Delete(id int) error
+	Reveal(id int) (*Snippet, error)
+	DeleteExpired(limit int) (int, error)
+	History(current *Snippet) ([]*Revision, error)
+	Latest() ([]*Snippet, error)
+	List(req PageRequest) (*SnippetPage, error)
+	ByTag(tag string, req PageRequest) (*SnippetPage, error)
+	Tags(limit int) ([]*TagCount, error)
+	ByUser(userID int) ([]*Snippet, error)
+	Search(query string, page, pageSize int) (*SearchResults, error)
+}
+
+// UserStore is implemented by every user storage backend.
+type UserStore interface {
+	Insert(name, email, password string) error
+	Authenticate(email, password string) (int, error)
+	Exists(id int) (bool, error)
+	Get(id int) (*User, error)
+}
+
+// TokenStore is implemented by every personal access token storage backend.
+type TokenStore interface {
+	Insert(userID int, name, scope string, days int) (string, error)
+	Authenticate(plaintext string) (*Token, error)
+	ForUser(userID int) ([]*Token, error)
+	Delete(id, userID int) error
+}
+
+var (
+	_ SnippetStore = (*SnippetModel)(nil)
+	_ UserStore    = (*UserModel)(nil)
+	_ TokenStore   = (*TokenModel)(nil)
+)
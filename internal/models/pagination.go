@@ -0,0 +1,196 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SortNewest   = "newest"
+	SortOldest   = "oldest"
+	SortExpiring = "expiring"
+)
+
+// Sorts lists the orders in which snippets can be listed.
+var Sorts = []string{SortNewest, SortOldest, SortExpiring}
+
+var ErrInvalidCursor = errors.New("models: invalid cursor")
+
+// Cursor marks a position in a keyset-paginated listing: the sort key and ID
+// of the snippet at the edge of a page. Backward cursors select the page
+// before that snippet rather than the page after it.
+type Cursor struct {
+	Sort     string
+	Key      time.Time
+	ID       int
+	Backward bool
+}
+
+// Encode returns the cursor as an opaque, URL-safe string.
+func (c Cursor) Encode() string {
+	direction := "next"
+	if c.Backward {
+		direction = "prev"
+	}
+
+	// The key is split into seconds and nanoseconds, as Never is too
+	// far in the future to be given in nanoseconds alone.
+	raw := fmt.Sprintf("%s.%d.%d.%d.%s", c.Sort, c.Key.Unix(), c.Key.Nanosecond(), c.ID, direction)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor parses a string produced by Cursor.Encode. It returns
+// ErrInvalidCursor if s isn't a valid cursor.
+func DecodeCursor(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	parts := strings.Split(string(raw), ".")
+	if len(parts) != 5 || !validSort(parts[0]) || (parts[4] != "next" && parts[4] != "prev") {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	sec, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	nsec, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil || nsec < 0 || nsec >= int64(time.Second) {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	id, err := strconv.Atoi(parts[3])
+	if err != nil || id < 1 {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	return Cursor{
+		Sort:     parts[0],
+		Key:      time.Unix(sec, nsec).UTC(),
+		ID:       id,
+		Backward: parts[4] == "prev",
+	}, nil
+}
+
+func validSort(order string) bool {
+	for _, s := range Sorts {
+		if s == order {
+			return true
+		}
+	}
+	return false
+}
+
+// PageRequest describes one page of a snippet listing. A nil Cursor selects
+// the first page.
+type PageRequest struct {
+	Sort   string
+	Size   int
+	Cursor *Cursor
+}
+
+// SnippetPage is one page of a snippet listing. Next and Prev are encoded
+// cursors for the adjacent pages, or empty if there is no such page.
+type SnippetPage struct {
+	Snippets []*Snippet
+	Sort     string
+	Size     int
+	Next     string
+	Prev     string
+}
+
+// sortKey returns the value that snippets are ordered by for the sort.
+func sortKey(s *Snippet, order string) time.Time {
+	if order == SortExpiring {
+		return s.Expires
+	}
+	return s.Created
+}
+
+// ascending reports whether the sort lists smaller keys first.
+func ascending(order string) bool {
+	return order != SortNewest
+}
+
+// newSnippetPage builds a page from up to req.Size+1 rows, fetched in the
+// direction given by the request's cursor. The extra row, if present, shows
+// that there is another page in that direction.
+func newSnippetPage(req PageRequest, rows []*Snippet) *SnippetPage {
+	backward := req.Cursor != nil && req.Cursor.Backward
+	more := len(rows) > req.Size
+	if more {
+		rows = rows[:req.Size]
+	}
+
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	page := &SnippetPage{Snippets: rows, Sort: req.Sort, Size: req.Size}
+	if len(rows) == 0 {
+		return page
+	}
+
+	first, last := rows[0], rows[len(rows)-1]
+
+	if more || backward {
+		page.Next = Cursor{Sort: req.Sort, Key: sortKey(last, req.Sort), ID: last.ID}.Encode()
+	}
+	if (backward && more) || (!backward && req.Cursor != nil) {
+		page.Prev = Cursor{Sort: req.Sort, Key: sortKey(first, req.Sort), ID: first.ID, Backward: true}.Encode()
+	}
+
+	return page
+}
+
+// ListSnippets applies a page request to snippets already filtered down to
+// the listable ones. It is used by backends which can't push the keyset
+// query down to a database.
+func ListSnippets(req PageRequest, snippets []*Snippet) *SnippetPage {
+	asc := ascending(req.Sort)
+	if req.Cursor != nil && req.Cursor.Backward {
+		asc = !asc
+	}
+
+	// less orders a before b in the direction being fetched.
+	less := func(a, b *Snippet) bool {
+		ka, kb := sortKey(a, req.Sort), sortKey(b, req.Sort)
+		if !ka.Equal(kb) {
+			return ka.Before(kb) == asc
+		}
+		if a.ID == b.ID {
+			return false
+		}
+		return (a.ID < b.ID) == asc
+	}
+
+	sorted := append([]*Snippet(nil), snippets...)
+	sort.Slice(sorted, func(i, j int) bool { return less(sorted[i], sorted[j]) })
+
+	var rows []*Snippet
+	for _, s := range sorted {
+		if req.Cursor != nil {
+			boundary := &Snippet{ID: req.Cursor.ID, Created: req.Cursor.Key, Expires: req.Cursor.Key}
+			if !less(boundary, s) {
+				continue
+			}
+		}
+
+		rows = append(rows, s)
+		if len(rows) > req.Size {
+			break
+		}
+	}
+
+	return newSnippetPage(req, rows)
+}
@@ -0,0 +1,227 @@
+package models_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/YelzhanWeb/snippetbox/internal/models"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor models.Cursor
+	}{
+		{"newest", models.Cursor{Sort: models.SortNewest, Key: time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC), ID: 7}},
+		{"nanoseconds", models.Cursor{Sort: models.SortOldest, Key: time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.UTC), ID: 1}},
+		{"never", models.Cursor{Sort: models.SortExpiring, Key: models.Never, ID: 42}},
+		{"before the epoch", models.Cursor{Sort: models.SortOldest, Key: time.Date(1969, 12, 31, 23, 59, 59, 500, time.UTC), ID: 3}},
+		{"backward", models.Cursor{Sort: models.SortNewest, Key: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), ID: 9, Backward: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := models.DecodeCursor(tt.cursor.Encode())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.cursor {
+				t.Errorf("got %+v; want %+v", got, tt.cursor)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"empty", ""},
+		{"not base64", "!!!"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte("newest.1.0.1.next"))},
+		{"too few parts", encode("newest.1.0.next")},
+		{"too many parts", encode("newest.1.0.1.next.x")},
+		{"unknown sort", encode("random.1.0.1.next")},
+		{"unknown direction", encode("newest.1.0.1.sideways")},
+		{"bad seconds", encode("newest.x.0.1.next")},
+		{"bad nanoseconds", encode("newest.1.x.1.next")},
+		{"negative nanoseconds", encode("newest.1.-1.1.next")},
+		{"nanoseconds overflow", encode("newest.1.1000000000.1.next")},
+		{"bad id", encode("newest.1.0.x.next")},
+		{"zero id", encode("newest.1.0.0.next")},
+		{"negative id", encode("newest.1.0.-5.next")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := models.DecodeCursor(tt.cursor)
+			if !errors.Is(err, models.ErrInvalidCursor) {
+				t.Errorf("got %v; want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+// pagingSnippets returns snippets with clashing creation and expiry times,
+// so that ordering relies on the ID as a tie-breaker, and one which never
+// expires. Their times are far in the future, so that none has expired.
+func pagingSnippets() []*models.Snippet {
+	base := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+	offsets := []int{3, 1, 4, 1, 5, 9, 2, 6, 5, 3}
+
+	var snippets []*models.Snippet
+	for i, o := range offsets {
+		snippets = append(snippets, &models.Snippet{
+			ID:         i + 1,
+			Title:      fmt.Sprintf("Snippet %d", i+1),
+			Content:    "content",
+			Language:   "text",
+			Visibility: models.VisibilityPublic,
+			Created:    base.Add(time.Duration(o) * time.Minute),
+			Expires:    base.Add(time.Duration(10-o) * 24 * time.Hour),
+		})
+	}
+	snippets[len(snippets)-1].Expires = models.Never
+
+	return snippets
+}
+
+// sortedIDs returns the IDs of snippets in the order of the sort.
+func sortedIDs(snippets []*models.Snippet, order string) []int {
+	sorted := slices.Clone(snippets)
+	slices.SortFunc(sorted, func(a, b *models.Snippet) int {
+		ka, kb := a.Created, b.Created
+		if order == models.SortExpiring {
+			ka, kb = a.Expires, b.Expires
+		}
+		c := ka.Compare(kb)
+		if c == 0 {
+			c = a.ID - b.ID
+		}
+		if order == models.SortNewest {
+			c = -c
+		}
+		return c
+	})
+
+	var ids []int
+	for _, s := range sorted {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+func pageIDs(page *models.SnippetPage) []int {
+	var ids []int
+	for _, s := range page.Snippets {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+// testPaging follows the Next cursors from the first page to the last, then
+// the Prev cursors back again, checking that every snippet is listed once,
+// in order, and that both walks see the same pages.
+func testPaging(t *testing.T, list func(models.PageRequest) *models.SnippetPage, snippets []*models.Snippet) {
+	for _, order := range models.Sorts {
+		want := sortedIDs(snippets, order)
+
+		for _, size := range []int{1, 3, 4, len(want), len(want) + 1} {
+			t.Run(fmt.Sprintf("%s/%d", order, size), func(t *testing.T) {
+				var forward []*models.SnippetPage
+				req := models.PageRequest{Sort: order, Size: size}
+				for len(forward) <= len(want) {
+					page := list(req)
+					forward = append(forward, page)
+					if page.Next == "" {
+						break
+					}
+
+					cursor, err := models.DecodeCursor(page.Next)
+					if err != nil {
+						t.Fatal(err)
+					}
+					req.Cursor = &cursor
+				}
+
+				var ids []int
+				for _, page := range forward {
+					ids = append(ids, pageIDs(page)...)
+				}
+				if !slices.Equal(ids, want) {
+					t.Fatalf("forward walk listed %v; want %v", ids, want)
+				}
+				if forward[0].Prev != "" {
+					t.Error("the first page has a previous page")
+				}
+
+				for i := len(forward) - 1; i > 0; i-- {
+					cursor, err := models.DecodeCursor(forward[i].Prev)
+					if err != nil {
+						t.Fatalf("page %d: %v", i, err)
+					}
+					if !cursor.Backward {
+						t.Fatalf("page %d: Prev is a forward cursor", i)
+					}
+
+					page := list(models.PageRequest{Sort: order, Size: size, Cursor: &cursor})
+					if !slices.Equal(pageIDs(page), pageIDs(forward[i-1])) {
+						t.Errorf("backward walk got %v for page %d; want %v", pageIDs(page), i-1, pageIDs(forward[i-1]))
+					}
+					if page.Next != forward[i-1].Next {
+						t.Errorf("page %d has a different Next cursor when reached backward", i-1)
+					}
+					if (page.Prev == "") != (i-1 == 0) {
+						t.Errorf("page %d reached backward has Prev %q", i-1, page.Prev)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestListSnippetsPaging(t *testing.T) {
+	snippets := pagingSnippets()
+
+	testPaging(t, func(req models.PageRequest) *models.SnippetPage {
+		return models.ListSnippets(req, snippets)
+	}, snippets)
+}
+
+func TestListSnippetsEmpty(t *testing.T) {
+	page := models.ListSnippets(models.PageRequest{Sort: models.SortNewest, Size: 5}, nil)
+	if len(page.Snippets) != 0 || page.Next != "" || page.Prev != "" {
+		t.Errorf("got %d snippets, Next %q and Prev %q; want an empty page", len(page.Snippets), page.Next, page.Prev)
+	}
+}
+
+func TestSQLitePaging(t *testing.T) {
+	db := newSQLiteDB(t)
+	m := &models.SnippetModel{DB: db}
+
+	snippets := pagingSnippets()
+	for _, s := range snippets {
+		_, err := db.Exec(`INSERT INTO snippets (id, slug, title, content, language, visibility, created, expires, user_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, 1)`, s.ID, fmt.Sprintf("s%09d", s.ID), s.Title, s.Content, s.Language, s.Visibility, s.Created, s.Expires)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testPaging(t, func(req models.PageRequest) *models.SnippetPage {
+		page, err := m.List(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return page
+	}, snippets)
+}
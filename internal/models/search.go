@@ -0,0 +1,124 @@
+package models
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+
+	"github.com/YelzhanWeb/snippetbox/internal/search"
+	"github.com/mattn/go-sqlite3"
+)
+
+// excerptWidth is the length, in characters, of search result excerpts.
+const excerptWidth = 200
+
+type SearchResult struct {
+	Snippet *Snippet          `json:"snippet"`
+	Score   float64           `json:"score"`
+	Title   []search.Fragment `json:"title"`
+	Excerpt []search.Fragment `json:"excerpt"`
+}
+
+// SearchResults is one page of ranked search results. Total is the number
+// of matching snippets across all pages.
+type SearchResults struct {
+	Results  []*SearchResult
+	Page     int
+	PageSize int
+	Total    int
+}
+
+func (r *SearchResults) HasPrev() bool {
+	return r.Page > 1
+}
+
+func (r *SearchResults) HasNext() bool {
+	return r.Page*r.PageSize < r.Total
+}
+
+func (r *SearchResults) PrevPage() int {
+	return r.Page - 1
+}
+
+func (r *SearchResults) NextPage() int {
+	return r.Page + 1
+}
+
+// RankSearch scores the candidate snippets against the terms and returns the
+// requested page of results, best match first. It is used by stores without
+// a full-text index of their own.
+func RankSearch(terms []string, candidates []*Snippet, page, pageSize int) *SearchResults {
+	results := make([]*SearchResult, len(candidates))
+	for i, s := range candidates {
+		results[i] = &SearchResult{
+			Snippet: s,
+			Score:   search.Score(terms, s.Title, s.Content),
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Snippet.ID > results[j].Snippet.ID
+	})
+
+	start := min((page-1)*pageSize, len(results))
+	end := min(start+pageSize, len(results))
+
+	// Only build highlights for the results being returned.
+	for _, r := range results[start:end] {
+		r.Title = search.Highlight(terms, r.Snippet.Title)
+		r.Excerpt = search.Excerpt(terms, r.Snippet.Content, excerptWidth)
+	}
+
+	return &SearchResults{
+		Results:  results[start:end],
+		Page:     page,
+		PageSize: pageSize,
+		Total:    len(results),
+	}
+}
+
+// fullTextQuery holds the SQL fragments which match and rank snippets
+// against search terms using a database's full-text index. Each fragment
+// takes the arguments alongside it.
+type fullTextQuery struct {
+	join      string
+	match     string
+	matchArgs []any
+	rank      string
+	rankArgs  []any
+}
+
+// newFullTextQuery builds a query matching snippets whose title or content
+// has a word starting with any of the terms. Terms only contain letters,
+// digits and underscores, so need no quoting in either query syntax.
+func newFullTextQuery(db *sql.DB, terms []string) *fullTextQuery {
+	prefixes := make([]string, len(terms))
+	for i, t := range terms {
+		prefixes[i] = t + "*"
+	}
+
+	if _, ok := db.Driver().(*sqlite3.SQLiteDriver); ok {
+		// snippets_search is an FTS4 index, ranked with the bm25 function
+		// registered by pkg/db. Title matches count five times as much as
+		// content matches.
+		return &fullTextQuery{
+			join:      `INNER JOIN snippets_search ON snippets_search.docid = s.id`,
+			match:     `snippets_search MATCH ?`,
+			matchArgs: []any{strings.Join(prefixes, " OR ")},
+			rank:      `bm25(matchinfo(snippets_search, 'pcnalx'), 5.0, 1.0)`,
+		}
+	}
+
+	// MySQL's boolean mode matches any of the words by default. The title
+	// has an index of its own so that its matches can count for more.
+	against := strings.Join(prefixes, " ")
+	return &fullTextQuery{
+		match:     `MATCH (s.title, s.content) AGAINST (? IN BOOLEAN MODE)`,
+		matchArgs: []any{against},
+		rank:      `5 * MATCH (s.title) AGAINST (? IN BOOLEAN MODE) + MATCH (s.title, s.content) AGAINST (? IN BOOLEAN MODE)`,
+		rankArgs:  []any{against, against},
+	}
+}
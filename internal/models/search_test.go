@@ -0,0 +1,200 @@
+package models_test
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/YelzhanWeb/snippetbox/internal/migrate"
+	"github.com/YelzhanWeb/snippetbox/internal/models"
+	storage "github.com/YelzhanWeb/snippetbox/pkg/db"
+)
+
+// openSQLiteDB returns an empty, migrated SQLite database in a temporary
+// directory.
+func openSQLiteDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := "file:" + filepath.Join(t.TempDir(), "test.db") + "?_synchronous=OFF&_busy_timeout=5000"
+	db, err := storage.InitDB("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m, err := migrate.New(db, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// newSQLiteDB returns a migrated SQLite database with one user, Alice.
+func newSQLiteDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db := openSQLiteDB(t)
+
+	_, err := db.Exec(`INSERT INTO users (name, email, hashed_password, created) VALUES ('Alice', 'alice@example.com', '', ?)`, time.Now().UTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func insertSnippet(t *testing.T, m *models.SnippetModel, title, content, visibility string) int {
+	t.Helper()
+
+	slug, err := m.Insert(models.SnippetFields{
+		Title:      title,
+		Content:    content,
+		Language:   "text",
+		Visibility: visibility,
+		Expires:    time.Now().UTC().Add(time.Hour),
+		Tags:       []string{},
+	}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := m.GetBySlug(slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s.ID
+}
+
+func titles(results *models.SearchResults) []string {
+	var titles []string
+	for _, r := range results.Results {
+		titles = append(titles, r.Snippet.Title)
+	}
+	return titles
+}
+
+func TestSQLiteSearchRanksEveryMatch(t *testing.T) {
+	db := newSQLiteDB(t)
+	m := &models.SnippetModel{DB: db}
+
+	// The best match is the oldest of more than a thousand.
+	insertSnippet(t, m, "Needle in a haystack", "needle needle haystack", models.VisibilityPublic)
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	for i := range 1200 {
+		_, err = tx.Exec(`INSERT INTO snippets (slug, title, content, language, visibility, created, expires, user_id)
+		VALUES (?, ?, 'just haystack', 'text', 'public', ?, ?, 1)`, fmt.Sprintf("h%08d", i), fmt.Sprintf("Hay %d", i), now, now.Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := m.Search("haystack needle", 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if results.Total != 1201 {
+		t.Errorf("got total %d; want 1201", results.Total)
+	}
+	if len(results.Results) != 10 {
+		t.Fatalf("got %d results; want 10", len(results.Results))
+	}
+	if got := results.Results[0].Snippet.Title; got != "Needle in a haystack" {
+		t.Errorf("got best match %q; want the needle", got)
+	}
+	if !results.HasNext() {
+		t.Error("HasNext is false on the first of many pages")
+	}
+
+	last, err := m.Search("haystack", 121, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(last.Results) != 1 || last.HasNext() {
+		t.Errorf("got %d results on the last page, HasNext %t; want 1 and false", len(last.Results), last.HasNext())
+	}
+
+	beyond, err := m.Search("haystack", 500, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(beyond.Results) != 0 || beyond.Total != 1201 {
+		t.Errorf("got %d results and total %d beyond the last page; want 0 and 1201", len(beyond.Results), beyond.Total)
+	}
+}
+
+func TestSQLiteSearch(t *testing.T) {
+	db := newSQLiteDB(t)
+	m := &models.SnippetModel{DB: db}
+
+	insertSnippet(t, m, "HTTP server", "package main\n\nimport \"net/http\"", models.VisibilityPublic)
+	insertSnippet(t, m, "Notes", "an http_client helper", models.VisibilityPublic)
+	insertSnippet(t, m, "Private http", "http", models.VisibilityPrivate)
+	insertSnippet(t, m, "Unlisted http", "http", models.VisibilityUnlisted)
+	edited := insertSnippet(t, m, "Old title", "nothing to see", models.VisibilityPublic)
+	deleted := insertSnippet(t, m, "Doomed http", "http", models.VisibilityPublic)
+
+	err := m.Update(edited, models.SnippetFields{
+		Title:      "Über fetch",
+		Content:    "fetch with http",
+		Language:   "text",
+		Visibility: models.VisibilityPublic,
+		Expires:    time.Now().UTC().Add(time.Hour),
+		Tags:       []string{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.Delete(deleted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"http", []string{"HTTP server", "Über fetch", "Notes"}},
+		{"htt", []string{"HTTP server", "Über fetch", "Notes"}},
+		{"http_client", []string{"Notes"}},
+		{"über", []string{"Über fetch"}},
+		{"old", nil},
+		{"doomed", nil},
+		{"private unlisted", nil},
+		{"!!!", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results, err := m.Search(tt.query, 1, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := titles(results)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+			if results.Total != len(tt.want) {
+				t.Errorf("got total %d; want %d", results.Total, len(tt.want))
+			}
+		})
+	}
+}
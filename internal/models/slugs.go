@@ -0,0 +1,58 @@
+package models
+
+import (
+	"crypto/rand"
+	"strings"
+)
+
+const (
+	// SlugLength is the number of characters in a snippet slug.
+	SlugLength = 10
+
+	slugAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+	// maxSlugAttempts is how many slugs Insert tries before giving up on
+	// finding one that isn't taken.
+	maxSlugAttempts = 5
+)
+
+// newSlug is the slug generator used by Insert, replaced by tests to force
+// collisions.
+var newSlug = NewSlug
+
+// NewSlug returns a random base62 slug. Slugs always contain a letter, so
+// that they can't be mistaken for the integer IDs used by old URLs.
+func NewSlug() (string, error) {
+	// Bytes at or above 248 are discarded, so that every character of the
+	// 62 character alphabet is equally likely.
+	const limit = 256 - 256%len(slugAlphabet)
+
+	slug := make([]byte, 0, SlugLength)
+	buf := make([]byte, SlugLength*2)
+
+	for {
+		_, err := rand.Read(buf)
+		if err != nil {
+			return "", err
+		}
+
+		for _, b := range buf {
+			if int(b) < limit && len(slug) < SlugLength {
+				slug = append(slug, slugAlphabet[int(b)%len(slugAlphabet)])
+			}
+		}
+
+		if len(slug) < SlugLength {
+			continue
+		}
+
+		if strings.IndexFunc(string(slug), isLetter) >= 0 {
+			return string(slug), nil
+		}
+		slug = slug[:0]
+	}
+}
+
+func isLetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
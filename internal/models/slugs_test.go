@@ -0,0 +1,112 @@
+package models_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/YelzhanWeb/snippetbox/internal/models"
+)
+
+const base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+func TestNewSlug(t *testing.T) {
+	const n = 10000
+
+	seen := map[string]bool{}
+	counts := map[rune]int{}
+
+	for range n {
+		slug, err := models.NewSlug()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(slug) != models.SlugLength {
+			t.Fatalf("slug %q has %d characters; want %d", slug, len(slug), models.SlugLength)
+		}
+		if strings.Trim(slug, base62) != "" {
+			t.Fatalf("slug %q has characters outside the base62 alphabet", slug)
+		}
+		if strings.Trim(slug, "0123456789") == "" {
+			t.Fatalf("slug %q has no letters", slug)
+		}
+		if seen[slug] {
+			t.Fatalf("slug %q was generated twice", slug)
+		}
+		seen[slug] = true
+
+		for _, r := range slug {
+			counts[r]++
+		}
+	}
+
+	// Every character should appear about n*SlugLength/62 ≈ 1613 times.
+	for _, r := range base62 {
+		if c := counts[r]; c < 1200 || c > 2100 {
+			t.Errorf("%q appeared %d times; want about %d", r, c, n*models.SlugLength/len(base62))
+		}
+	}
+}
+
+// slugs returns a generator which returns each of the given slugs in turn.
+func slugs(list ...string) func() (string, error) {
+	return func() (string, error) {
+		slug := list[0]
+		list = list[1:]
+		return slug, nil
+	}
+}
+
+func TestInsertSlugCollision(t *testing.T) {
+	db := newSQLiteDB(t)
+	m := &models.SnippetModel{DB: db}
+
+	fields := models.SnippetFields{
+		Title:      "Title",
+		Content:    "Content",
+		Language:   "text",
+		Visibility: models.VisibilityPublic,
+		Expires:    time.Now().Add(time.Hour),
+	}
+
+	models.SetNewSlug(t, slugs("taken00001"))
+	_, err := m.Insert(fields, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("retry", func(t *testing.T) {
+		models.SetNewSlug(t, slugs("taken00001", "taken00001", "free000001"))
+
+		slug, err := m.Insert(fields, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if slug != "free000001" {
+			t.Errorf("got slug %q; want %q", slug, "free000001")
+		}
+
+		if _, err := m.GetBySlug("free000001"); err != nil {
+			t.Errorf("getting the new snippet: %v", err)
+		}
+	})
+
+	t.Run("give up", func(t *testing.T) {
+		var taken []string
+		for range models.MaxSlugAttempts {
+			taken = append(taken, "taken00001")
+		}
+		models.SetNewSlug(t, slugs(append(taken, "free000002")...))
+
+		_, err := m.Insert(fields, 1)
+		if err == nil {
+			t.Fatalf("got nil error after %d collisions", models.MaxSlugAttempts)
+		}
+
+		if _, err := m.GetBySlug("free000002"); !errors.Is(err, models.ErrNoRecord) {
+			t.Errorf("got %v getting a slug past the last attempt; want ErrNoRecord", err)
+		}
+	})
+}
@@ -3,48 +3,203 @@ package models
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
+
+	"github.com/YelzhanWeb/snippetbox/internal/search"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
 )
 
+// Visibilities lists the visibility levels a snippet can have. Public
+// snippets are listed and searchable, unlisted snippets can only be reached
+// by link, and private snippets can only be seen by their owner.
+var Visibilities = []string{VisibilityPublic, VisibilityUnlisted, VisibilityPrivate}
+
+// Snippet is a stored snippet. ID is an internal key; Slug is the random
+// identifier used in URLs.
 type Snippet struct {
-	ID      int
+	ID         int       `json:"-"`
+	Slug       string    `json:"slug"`
+	Title      string    `json:"title"`
+	Content    string    `json:"content"`
+	Language   string    `json:"language"`
+	Visibility string    `json:"visibility"`
+	MaxViews   int       `json:"max_views"`
+	Views      int       `json:"views"`
+	Protected  bool      `json:"protected"`
+	Encrypted  bool      `json:"encrypted"`
+	Created    time.Time `json:"created"`
+	Expires    time.Time `json:"expires"`
+	UserID     int       `json:"user_id"`
+	Author     string    `json:"author"`
+	Tags       []string  `json:"tags"`
+
+	HashedPassword []byte `json:"-"`
+}
+
+// VisibleTo reports whether the user with the given ID, or 0 for anonymous
+// users, may see the snippet.
+func (s *Snippet) VisibleTo(userID int) bool {
+	return s.Visibility != VisibilityPrivate || s.UserID == userID
+}
+
+// BurnsFor reports whether viewing the snippet uses up one of its limited
+// views, which is the case for everyone except its owner.
+func (s *Snippet) BurnsFor(userID int) bool {
+	return s.MaxViews > 0 && s.UserID != userID
+}
+
+// MatchesPassword reports whether password unlocks a password-protected
+// snippet.
+func (s *Snippet) MatchesPassword(password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword(s.HashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// NeverExpires reports whether the snippet was created to last forever.
+func (s *Snippet) NeverExpires() bool {
+	return s.Expires.Equal(Never)
+}
+
+// RemainingViews returns how many more times a snippet with limited views
+// can be revealed.
+func (s *Snippet) RemainingViews() int {
+	return max(s.MaxViews-s.Views, 0)
+}
+
+// SnippetFields holds the fields of a snippet chosen by its author. Expires
+// is Never for snippets which never expire. A MaxViews above zero makes the snippet
+// destroy itself once it has been revealed that many times. Password, if
+// set, is only used when the snippet is created, as is Encrypted, which marks
+// Content as ciphertext that only the browser can decrypt.
+type SnippetFields struct {
+	Title      string
+	Content    string
+	Language   string
+	Visibility string
+	MaxViews   int
+	Expires    time.Time
+	Tags       []string
+	Password   string
+	Encrypted  bool
+}
+
+// Revision is one version of a snippet's title and content. Versions are
+// numbered from 1, and Created is the time at which that version was written.
+type Revision struct {
+	Version int
 	Title   string
 	Content string
 	Created time.Time
-	Expires time.Time
+	Current bool
 }
 
 type SnippetModel struct {
-	DB *sql.DB
+	DB         *sql.DB
+	BcryptCost int
+}
+
+// selectSnippets selects the columns read by scanSnippet.
+const snippetColumns = `s.id, s.slug, s.title, s.content, s.language, s.visibility,
+	s.max_views, s.views, s.hashed_password, s.encrypted, s.created, s.expires, s.user_id, u.name`
+
+const selectSnippets = `SELECT ` + snippetColumns + `
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id`
+
+func scanSnippet(row interface{ Scan(...any) error }) (*Snippet, error) {
+	s := &Snippet{}
+	err := row.Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.Language, &s.Visibility,
+		&s.MaxViews, &s.Views, &s.HashedPassword, &s.Encrypted, &s.Created, &s.Expires, &s.UserID, &s.Author)
+	s.Protected = len(s.HashedPassword) > 0
+	return s, err
+}
+
+// Insert creates a snippet with a new random slug and returns the slug.
+func (m *SnippetModel) Insert(fields SnippetFields, userID int) (string, error) {
+	var hashedPassword any
+	if fields.Password != "" {
+		hash, err := HashPassword(fields.Password, m.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		hashedPassword = hash
+	}
+
+	for attempt := 1; ; attempt++ {
+		slug, err := newSlug()
+		if err != nil {
+			return "", err
+		}
+
+		err = m.insert(slug, fields, hashedPassword, userID)
+		if err == nil {
+			return slug, nil
+		}
+
+		if attempt == maxSlugAttempts || !isUniqueViolation(err, "idx_snippets_slug", "snippets.slug") {
+			return "", err
+		}
+	}
 }
 
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
+func (m *SnippetModel) insert(slug string, fields SnippetFields, hashedPassword any, userID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO snippets (slug, title, content, language, visibility, max_views, hashed_password, encrypted,
+	created, expires, user_id)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
-	stmt := `INSERT INTO snippets (title, content, created, expires)
-	VALUES (?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+	now := time.Now().UTC()
 
-	result, err := m.DB.Exec(stmt, title, content, expires)
+	result, err := tx.Exec(stmt, slug, fields.Title, fields.Content, fields.Language, fields.Visibility,
+		fields.MaxViews, hashedPassword, fields.Encrypted, now, fields.Expires.UTC(), userID)
 	if err != nil {
-		return 0, err
+		return err
 	}
 
 	id, err := result.LastInsertId()
 	if err != nil {
-		return 0, err
+		return err
+	}
+
+	err = setTags(tx, int(id), fields.Tags)
+	if err != nil {
+		return err
 	}
 
-	return int(id), nil
+	return tx.Commit()
 }
 
 func (m *SnippetModel) Get(id int) (*Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > UTC_TIMESTAMP() AND id = ?`
+	return m.get("s.id = ?", id)
+}
 
-	row := m.DB.QueryRow(stmt, id)
+func (m *SnippetModel) GetBySlug(slug string) (*Snippet, error) {
+	return m.get("s.slug = ?", slug)
+}
 
-	s := &Snippet{}
+func (m *SnippetModel) get(condition string, arg any) (*Snippet, error) {
+	stmt := selectSnippets + `
+	WHERE s.expires > ? AND ` + condition
 
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	s, err := scanSnippet(m.DB.QueryRow(stmt, time.Now().UTC(), arg))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoRecord
@@ -53,26 +208,437 @@ func (m *SnippetModel) Get(id int) (*Snippet, error) {
 		}
 	}
 
+	err = loadTags(m.DB, []*Snippet{s})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Update replaces the snippet's fields. If the title or content changed, the
+// previous version is kept as a revision.
+func (m *SnippetModel) Update(id int, fields SnippetFields) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The old version is compared in Go rather than SQL, as MySQL's default
+	// collation would treat changes of case or accents as no change at all.
+	var title, content string
+	err = tx.QueryRow(`SELECT title, content FROM snippets WHERE id = ?`, id).Scan(&title, &content)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	if err == nil && (title != fields.Title || content != fields.Content) {
+		stmt := `INSERT INTO snippet_revisions (snippet_id, title, content, created) VALUES (?, ?, ?, ?)`
+
+		_, err = tx.Exec(stmt, id, title, content, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+	}
+
+	stmt := `UPDATE snippets SET title = ?, content = ?, language = ?, visibility = ?, max_views = ?, expires = ?
+	WHERE id = ?`
+
+	_, err = tx.Exec(stmt, fields.Title, fields.Content, fields.Language, fields.Visibility,
+		fields.MaxViews, fields.Expires.UTC(), id)
+	if err != nil {
+		return err
+	}
+
+	err = setTags(tx, id, fields.Tags)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m *SnippetModel) Delete(id int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = deleteSnippet(tx, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Reveal records a view of a snippet with limited views and returns it. The
+// view which uses up the last of its views also deletes it, in the same
+// transaction, so no two callers can both see the snippet's final view. It
+// returns ErrNoRecord if the snippet doesn't exist, has expired or has no
+// views left.
+func (m *SnippetModel) Reveal(id int) (*Snippet, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE snippets SET views = views + 1
+	WHERE id = ? AND expires > ? AND max_views > 0 AND views < max_views`
+
+	result, err := tx.Exec(stmt, id, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrNoRecord
+	}
+
+	s, err := scanSnippet(tx.QueryRow(selectSnippets+` WHERE s.id = ?`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	err = loadTags(tx, []*Snippet{s})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Views >= s.MaxViews {
+		err = deleteSnippet(tx, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
 	return s, nil
 }
 
+// DeleteExpired permanently deletes up to limit expired snippets, along with
+// their tags and revisions, and returns how many it deleted. Expired snippets
+// can no longer be seen or edited, so none can be brought back to life while
+// they are being deleted.
+func (m *SnippetModel) DeleteExpired(limit int) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id FROM snippets WHERE expires <= ? ORDER BY expires ASC LIMIT ?`,
+		time.Now().UTC(), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var ids []any
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	in := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+
+	for _, table := range []string{"snippet_revisions", "snippet_tags"} {
+		_, err = tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE snippet_id IN (%s)`, table, in), ids...)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(`DELETE FROM snippets WHERE id IN (%s)`, in), ids...)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return len(ids), nil
+}
+
+func deleteSnippet(tx *sql.Tx, id int) error {
+	_, err := tx.Exec(`DELETE FROM snippet_revisions WHERE snippet_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM snippet_tags WHERE snippet_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM snippets WHERE id = ?`, id)
+	return err
+}
+
+// History returns every version of the snippet, oldest first. The last
+// entry is always the current version.
+func (m *SnippetModel) History(current *Snippet) ([]*Revision, error) {
+	stmt := `SELECT title, content, created FROM snippet_revisions
+	WHERE snippet_id = ? ORDER BY id ASC`
+
+	rows, err := m.DB.Query(stmt, current.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	revisions := []*Revision{}
+	written := current.Created
+
+	for rows.Next() {
+		r := &Revision{Version: len(revisions) + 1}
+
+		// Each row records when its version was replaced, which is when
+		// the following version was written.
+		var replaced time.Time
+
+		err = rows.Scan(&r.Title, &r.Content, &replaced)
+		if err != nil {
+			return nil, err
+		}
+
+		r.Created = written
+		written = replaced
+
+		revisions = append(revisions, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	revisions = append(revisions, &Revision{
+		Version: len(revisions) + 1,
+		Title:   current.Title,
+		Content: current.Content,
+		Created: written,
+		Current: true,
+	})
+
+	return revisions, nil
+}
+
 func (m *SnippetModel) Latest() ([]*Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > UTC_TIMESTAMP() ORDER BY id DESC LIMIT 10`
+	stmt := selectSnippets + `
+	WHERE s.expires > ? AND s.visibility = 'public' ORDER BY s.id DESC LIMIT 10`
+
+	return m.query(stmt, time.Now().UTC())
+}
+
+// List returns one page of the non-expired public snippets.
+func (m *SnippetModel) List(req PageRequest) (*SnippetPage, error) {
+	return m.page(req, "", nil)
+}
 
-	rows, err := m.DB.Query(stmt)
+// ByTag returns one page of the non-expired public snippets carrying the
+// tag.
+func (m *SnippetModel) ByTag(tag string, req PageRequest) (*SnippetPage, error) {
+	return m.page(req, "s.id IN (SELECT snippet_id FROM snippet_tags WHERE tag = ?)", []any{tag})
+}
+
+// Tags returns the limit tags carried by the most non-expired public
+// snippets, weighted for a tag cloud and in alphabetical order.
+func (m *SnippetModel) Tags(limit int) ([]*TagCount, error) {
+	stmt := `SELECT t.tag, COUNT(*) FROM snippet_tags t
+	INNER JOIN snippets s ON s.id = t.snippet_id
+	WHERE s.expires > ? AND s.visibility = 'public' GROUP BY t.tag ORDER BY COUNT(*) DESC, t.tag ASC LIMIT ?`
+
+	rows, err := m.DB.Query(stmt, time.Now().UTC(), limit)
 	if err != nil {
 		return nil, err
 	}
 
 	defer rows.Close()
 
-	snippets := []*Snippet{}
+	tags := []*TagCount{}
+
+	for rows.Next() {
+		t := &TagCount{}
+
+		err = rows.Scan(&t.Name, &t.Count)
+		if err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tagCloud(tags), nil
+}
+
+// page returns one page of the non-expired public snippets matching the
+// condition, using keyset pagination on the sort key and ID.
+func (m *SnippetModel) page(req PageRequest, condition string, conditionArgs []any) (*SnippetPage, error) {
+	column := "s.created"
+	if req.Sort == SortExpiring {
+		column = "s.expires"
+	}
+
+	asc := ascending(req.Sort)
+	if req.Cursor != nil && req.Cursor.Backward {
+		asc = !asc
+	}
+
+	order, cmp := "DESC", "<"
+	if asc {
+		order, cmp = "ASC", ">"
+	}
+
+	where := "s.expires > ? AND s.visibility = 'public'"
+	args := []any{time.Now().UTC()}
+
+	if condition != "" {
+		where += " AND " + condition
+		args = append(args, conditionArgs...)
+	}
+
+	if req.Cursor != nil {
+		where += fmt.Sprintf(" AND (%[1]s %[2]s ? OR (%[1]s = ? AND s.id %[2]s ?))", column, cmp)
+		args = append(args, req.Cursor.Key, req.Cursor.Key, req.Cursor.ID)
+	}
+
+	stmt := fmt.Sprintf(selectSnippets+`
+	WHERE %s ORDER BY %s %s, s.id %s LIMIT ?`, where, column, order, order)
+	args = append(args, req.Size+1)
+
+	rows, err := m.query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSnippetPage(req, rows), nil
+}
+
+// ByUser returns the non-expired snippets created by the given user, newest
+// first, whatever their visibility.
+func (m *SnippetModel) ByUser(userID int) ([]*Snippet, error) {
+	stmt := selectSnippets + `
+	WHERE s.expires > ? AND s.user_id = ? ORDER BY s.id DESC`
+
+	return m.query(stmt, time.Now().UTC(), userID)
+}
+
+// Search returns one page of the non-expired public snippets whose title or
+// content contains a word starting with any of the words in query, best
+// match first. Matching and ranking use the database's full-text index, so
+// every match is considered however many there are.
+func (m *SnippetModel) Search(query string, page, pageSize int) (*SearchResults, error) {
+	terms := search.Terms(query)
+	if len(terms) == 0 {
+		return RankSearch(nil, nil, page, pageSize), nil
+	}
+
+	q := newFullTextQuery(m.DB, terms)
+	filter := `s.expires > ? AND s.visibility = 'public' AND NOT s.encrypted AND ` + q.match
+	filterArgs := append([]any{time.Now().UTC()}, q.matchArgs...)
 
+	results := &SearchResults{Results: []*SearchResult{}, Page: page, PageSize: pageSize}
+
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM snippets s `+q.join+` WHERE `+filter, filterArgs...).Scan(&results.Total)
+	if err != nil {
+		return nil, err
+	}
+	if results.Total <= (page-1)*pageSize {
+		return results, nil
+	}
+
+	stmt := `SELECT ` + snippetColumns + `, ` + q.rank + ` AS score
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id ` + q.join + `
+	WHERE ` + filter + `
+	ORDER BY score DESC, s.id DESC LIMIT ? OFFSET ?`
+
+	args := append(append(q.rankArgs, filterArgs...), pageSize, (page-1)*pageSize)
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snippets []*Snippet
 	for rows.Next() {
-		s := &Snippet{}
+		r := &SearchResult{}
+		r.Snippet, err = scanSnippet(scanExtra{rows, []any{&r.Score}})
+		if err != nil {
+			return nil, err
+		}
+
+		r.Title = search.Highlight(terms, r.Snippet.Title)
+		r.Excerpt = search.Excerpt(terms, r.Snippet.Content, excerptWidth)
+
+		results.Results = append(results.Results, r)
+		snippets = append(snippets, r.Snippet)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = loadTags(m.DB, snippets)
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// scanExtra scans a row's leading columns into the destinations given to
+// Scan, and any columns after them into extra.
+type scanExtra struct {
+	row   interface{ Scan(...any) error }
+	extra []any
+}
+
+func (s scanExtra) Scan(dest ...any) error {
+	return s.row.Scan(append(dest, s.extra...)...)
+}
+
+func (m *SnippetModel) query(stmt string, args ...any) ([]*Snippet, error) {
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
 
-		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s, err := scanSnippet(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -84,5 +650,72 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 		return nil, err
 	}
 
+	err = loadTags(m.DB, snippets)
+	if err != nil {
+		return nil, err
+	}
+
 	return snippets, nil
 }
+
+// querier is satisfied by both *sql.DB and *sql.Tx.
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// loadTags fills in the tags of the snippets, in alphabetical order.
+func loadTags(q querier, snippets []*Snippet) error {
+	if len(snippets) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*Snippet, len(snippets))
+	args := make([]any, len(snippets))
+
+	for i, s := range snippets {
+		s.Tags = []string{}
+		byID[s.ID] = s
+		args[i] = s.ID
+	}
+
+	stmt := fmt.Sprintf(`SELECT snippet_id, tag FROM snippet_tags
+	WHERE snippet_id IN (%s) ORDER BY tag ASC`, strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", "))
+
+	rows, err := q.Query(stmt, args...)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var tag string
+
+		err = rows.Scan(&id, &tag)
+		if err != nil {
+			return err
+		}
+
+		byID[id].Tags = append(byID[id].Tags, tag)
+	}
+
+	return rows.Err()
+}
+
+// setTags replaces the tags of the snippet with the given ID.
+func setTags(tx *sql.Tx, id int, tags []string) error {
+	_, err := tx.Exec(`DELETE FROM snippet_tags WHERE snippet_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		_, err = tx.Exec(`INSERT INTO snippet_tags (snippet_id, tag) VALUES (?, ?)`, id, tag)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
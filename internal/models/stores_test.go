@@ -0,0 +1,20 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/YelzhanWeb/snippetbox/internal/models"
+	"github.com/YelzhanWeb/snippetbox/internal/models/storetest"
+)
+
+func TestSQLiteStores(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storetest.Stores {
+		db := openSQLiteDB(t)
+
+		return storetest.Stores{
+			Snippets: &models.SnippetModel{DB: db, BcryptCost: 4},
+			Users:    &models.UserModel{DB: db, BcryptCost: 4},
+			Tokens:   &models.TokenModel{DB: db},
+		}
+	})
+}
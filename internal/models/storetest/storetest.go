@@ -0,0 +1,730 @@
+// Package storetest checks that a storage backend implements the models
+// store interfaces as the rest of the application expects, so that every
+// backend behaves the same way.
+package storetest
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/YelzhanWeb/snippetbox/internal/models"
+)
+
+// Stores are the stores of one backend.
+type Stores struct {
+	Snippets models.SnippetStore
+	Users    models.UserStore
+	Tokens   models.TokenStore
+}
+
+// Run tests the stores returned by open, which is called for each test and
+// must return empty stores.
+func Run(t *testing.T, open func(t *testing.T) Stores) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s Stores)
+	}{
+		{"Users", testUsers},
+		{"Tokens", testTokens},
+		{"InsertGet", testInsertGet},
+		{"Copies", testCopies},
+		{"Update", testUpdate},
+		{"Delete", testDelete},
+		{"Listings", testListings},
+		{"Search", testSearch},
+		{"DeleteExpired", testDeleteExpired},
+		{"Reveal", testReveal},
+		{"ConcurrentReveal", testConcurrentReveal},
+		{"ConcurrentAccess", testConcurrentAccess},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, open(t))
+		})
+	}
+}
+
+const password = "pa55word"
+
+// newUser creates a user and returns their ID.
+func newUser(t *testing.T, s Stores, name string) int {
+	t.Helper()
+
+	email := name + "@example.com"
+	err := s.Users.Insert(name, email, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := s.Users.Authenticate(email, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// fields returns the fields of a snippet which expires in an hour.
+func fields(title, visibility string, tags ...string) models.SnippetFields {
+	return models.SnippetFields{
+		Title:      title,
+		Content:    "content of " + title,
+		Language:   "text",
+		Visibility: visibility,
+		Expires:    time.Now().UTC().Add(time.Hour).Truncate(time.Second),
+		Tags:       append([]string{}, tags...),
+	}
+}
+
+// insert creates a snippet and returns it as stored.
+func insert(t *testing.T, s Stores, f models.SnippetFields, userID int) *models.Snippet {
+	t.Helper()
+
+	slug, err := s.Snippets.Insert(f, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snippet, err := s.Snippets.GetBySlug(slug)
+	if err != nil {
+		t.Fatalf("getting the new snippet: %v", err)
+	}
+	return snippet
+}
+
+// insertExpired creates a snippet which has already expired and returns it
+// as it was before it expired, since it can no longer be looked up.
+func insertExpired(t *testing.T, s Stores, f models.SnippetFields, userID int) *models.Snippet {
+	t.Helper()
+
+	snippet := insert(t, s, f, userID)
+
+	f.Expires = time.Now().UTC().Add(-time.Minute)
+	update(t, s, snippet.ID, f)
+	return snippet
+}
+
+func update(t *testing.T, s Stores, id int, f models.SnippetFields) {
+	t.Helper()
+
+	err := s.Snippets.Update(id, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func titles(snippets []*models.Snippet) []string {
+	titles := []string{}
+	for _, s := range snippets {
+		titles = append(titles, s.Title)
+	}
+	return titles
+}
+
+func checkTitles(t *testing.T, what string, snippets []*models.Snippet, want ...string) {
+	t.Helper()
+
+	if got := titles(snippets); !slices.Equal(got, want) {
+		t.Errorf("%s: got %q; want %q", what, got, want)
+	}
+}
+
+func testUsers(t *testing.T, s Stores) {
+	id := newUser(t, s, "alice")
+
+	for _, email := range []string{"alice@example.com", "ALICE@example.com"} {
+		err := s.Users.Insert("Another Alice", email, password)
+		if !errors.Is(err, models.ErrDuplicateEmail) {
+			t.Errorf("inserting %s again: got %v; want ErrDuplicateEmail", email, err)
+		}
+	}
+
+	for _, c := range []struct{ email, password string }{
+		{"alice@example.com", "wrong password"},
+		{"bob@example.com", password},
+	} {
+		_, err := s.Users.Authenticate(c.email, c.password)
+		if !errors.Is(err, models.ErrInvalidCredentials) {
+			t.Errorf("authenticating %s with %q: got %v; want ErrInvalidCredentials", c.email, c.password, err)
+		}
+	}
+
+	if exists, err := s.Users.Exists(id); err != nil || !exists {
+		t.Errorf("Exists(%d) = %t, %v; want true", id, exists, err)
+	}
+	if exists, err := s.Users.Exists(id + 100); err != nil || exists {
+		t.Errorf("Exists(%d) = %t, %v; want false", id+100, exists, err)
+	}
+
+	user, err := s.Users.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != id || user.Name != "alice" || user.Email != "alice@example.com" || user.Created.IsZero() {
+		t.Errorf("got user %+v", user)
+	}
+	if len(user.HashedPassword) != 0 {
+		t.Error("Get returned the password hash")
+	}
+
+	_, err = s.Users.Get(id + 100)
+	if !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("getting a missing user: got %v; want ErrNoRecord", err)
+	}
+}
+
+func testTokens(t *testing.T, s Stores) {
+	alice := newUser(t, s, "alice")
+	bob := newUser(t, s, "bob")
+
+	expiring, err := s.Tokens.Insert(alice, "ci", models.ScopeRead, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lasting, err := s.Tokens.Insert(alice, "laptop", models.ScopeWrite, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Tokens.Insert(bob, "bob's", models.ScopeWrite, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := s.Tokens.Authenticate(expiring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.UserID != alice || token.Name != "ci" || token.Scope != models.ScopeRead {
+		t.Errorf("got token %+v", token)
+	}
+	if d := time.Until(token.Expires); d < 29*24*time.Hour || d > 30*24*time.Hour {
+		t.Errorf("got token expiring in %s; want 30 days", d)
+	}
+
+	token, err = s.Tokens.Authenticate(lasting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !token.Expires.IsZero() {
+		t.Errorf("got token expiring at %s; want never", token.Expires)
+	}
+
+	_, err = s.Tokens.Authenticate(expiring + "x")
+	if !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("authenticating a wrong token: got %v; want ErrNoRecord", err)
+	}
+
+	tokens, err := s.Tokens.ForUser(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tok := range tokens {
+		names = append(names, tok.Name)
+	}
+	if !slices.Equal(names, []string{"laptop", "ci"}) {
+		t.Errorf("ForUser: got %q; want newest first", names)
+	}
+
+	err = s.Tokens.Delete(tokens[0].ID, bob)
+	if !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("deleting another user's token: got %v; want ErrNoRecord", err)
+	}
+
+	err = s.Tokens.Delete(tokens[0].ID, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Tokens.Authenticate(lasting)
+	if !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("authenticating a deleted token: got %v; want ErrNoRecord", err)
+	}
+}
+
+func testInsertGet(t *testing.T, s Stores) {
+	alice := newUser(t, s, "alice")
+
+	f := fields("Hello", models.VisibilityUnlisted, "web", "go")
+	f.Language = "go"
+	f.MaxViews = 3
+
+	snippet := insert(t, s, f, alice)
+
+	if len(snippet.Slug) != 10 {
+		t.Errorf("got slug %q; want 10 characters", snippet.Slug)
+	}
+	if snippet.Title != f.Title || snippet.Content != f.Content || snippet.Language != "go" ||
+		snippet.Visibility != models.VisibilityUnlisted || snippet.MaxViews != 3 || snippet.Views != 0 {
+		t.Errorf("got snippet %+v", snippet)
+	}
+	if !snippet.Expires.Equal(f.Expires) {
+		t.Errorf("got expiry %s; want %s", snippet.Expires, f.Expires)
+	}
+	if time.Since(snippet.Created) > time.Minute {
+		t.Errorf("got creation time %s", snippet.Created)
+	}
+	if snippet.UserID != alice || snippet.Author != "alice" {
+		t.Errorf("got author %d %q; want %d alice", snippet.UserID, snippet.Author, alice)
+	}
+	if !slices.Equal(snippet.Tags, []string{"go", "web"}) {
+		t.Errorf("got tags %q; want them sorted", snippet.Tags)
+	}
+	if snippet.Protected || snippet.Encrypted {
+		t.Errorf("got protected %t, encrypted %t; want neither", snippet.Protected, snippet.Encrypted)
+	}
+
+	byID, err := s.Snippets.Get(snippet.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byID.Slug != snippet.Slug {
+		t.Errorf("Get returned %q; want %q", byID.Slug, snippet.Slug)
+	}
+
+	f = fields("Secret", models.VisibilityPrivate)
+	f.Password = "hunter22"
+	f.Encrypted = true
+	protected := insert(t, s, f, alice)
+	if !protected.Protected || !protected.Encrypted {
+		t.Errorf("got protected %t, encrypted %t; want both", protected.Protected, protected.Encrypted)
+	}
+	for _, c := range []struct {
+		password string
+		want     bool
+	}{{"hunter22", true}, {"hunter2", false}} {
+		if ok, err := protected.MatchesPassword(c.password); ok != c.want || err != nil {
+			t.Errorf("MatchesPassword(%q) = %t, %v; want %t", c.password, ok, err, c.want)
+		}
+	}
+
+	expired := insertExpired(t, s, fields("Gone", models.VisibilityPublic), alice)
+
+	for name, get := range map[string]func() (*models.Snippet, error){
+		"missing ID":      func() (*models.Snippet, error) { return s.Snippets.Get(protected.ID + 100) },
+		"missing slug":    func() (*models.Snippet, error) { return s.Snippets.GetBySlug("aaaaaaaaaa") },
+		"empty slug":      func() (*models.Snippet, error) { return s.Snippets.GetBySlug("") },
+		"expired by ID":   func() (*models.Snippet, error) { return s.Snippets.Get(expired.ID) },
+		"expired by slug": func() (*models.Snippet, error) { return s.Snippets.GetBySlug(expired.Slug) },
+	} {
+		_, err := get()
+		if !errors.Is(err, models.ErrNoRecord) {
+			t.Errorf("%s: got %v; want ErrNoRecord", name, err)
+		}
+	}
+}
+
+// testCopies checks that callers can't change stored snippets through the
+// values returned to them.
+func testCopies(t *testing.T, s Stores) {
+	alice := newUser(t, s, "alice")
+	snippet := insert(t, s, fields("Original", models.VisibilityPublic, "go"), alice)
+
+	snippet.Title = "Changed"
+	snippet.Tags[0] = "changed"
+
+	for _, list := range [][]*models.Snippet{
+		must(s.Snippets.Latest()),
+		must(s.Snippets.ByUser(alice)),
+		must(s.Snippets.List(models.PageRequest{Sort: models.SortNewest, Size: 10})).Snippets,
+	} {
+		list[0].Title = "Changed"
+		list[0].Tags[0] = "changed"
+	}
+
+	got := must(s.Snippets.Get(snippet.ID))
+	if got.Title != "Original" || !slices.Equal(got.Tags, []string{"go"}) {
+		t.Errorf("stored snippet changed to %q with tags %q", got.Title, got.Tags)
+	}
+}
+
+func testUpdate(t *testing.T, s Stores) {
+	alice := newUser(t, s, "alice")
+	snippet := insert(t, s, fields("First", models.VisibilityPublic, "go"), alice)
+
+	second := fields("Second", models.VisibilityUnlisted, "web", "api")
+	second.Content = snippet.Content
+	second.Language = "markdown"
+	second.MaxViews = 5
+	second.Expires = second.Expires.Add(time.Hour)
+
+	update(t, s, snippet.ID, second)
+
+	got := must(s.Snippets.Get(snippet.ID))
+	if got.Title != "Second" || got.Language != "markdown" || got.Visibility != models.VisibilityUnlisted ||
+		got.MaxViews != 5 || !got.Expires.Equal(second.Expires) || !slices.Equal(got.Tags, []string{"api", "web"}) {
+		t.Errorf("got snippet %+v", got)
+	}
+	if got.Slug != snippet.Slug || !got.Created.Equal(snippet.Created) {
+		t.Error("Update changed the slug or creation time")
+	}
+
+	// Changing only settings doesn't make a new version.
+	settings := second
+	settings.Visibility = models.VisibilityPrivate
+	settings.Tags = []string{}
+	update(t, s, snippet.ID, settings)
+
+	third := settings
+	third.Title = "Third"
+	third.Content = "Third content"
+	update(t, s, snippet.ID, third)
+
+	// Changes of case or accents alone are changes too.
+	fourth := third
+	fourth.Title = "THIRD"
+	update(t, s, snippet.ID, fourth)
+
+	fifth := fourth
+	fifth.Content = "Third contént"
+	update(t, s, snippet.ID, fifth)
+
+	current := must(s.Snippets.Get(snippet.ID))
+	revisions := must(s.Snippets.History(current))
+
+	var got3 []string
+	for _, r := range revisions {
+		got3 = append(got3, fmt.Sprintf("%d %s %t", r.Version, r.Title, r.Current))
+	}
+	want := []string{"1 First false", "2 Second false", "3 Third false", "4 THIRD false", "5 THIRD true"}
+	if !slices.Equal(got3, want) {
+		t.Errorf("got history %q; want %q", got3, want)
+	}
+
+	if !revisions[0].Created.Equal(snippet.Created) {
+		t.Errorf("first version written at %s; want the creation time %s", revisions[0].Created, snippet.Created)
+	}
+	for i := 1; i < len(revisions); i++ {
+		if revisions[i].Created.Before(revisions[i-1].Created) {
+			t.Errorf("version %d written before version %d", i+1, i)
+		}
+	}
+	if revisions[1].Content != snippet.Content || revisions[3].Content != "Third content" || revisions[4].Content != "Third contént" {
+		t.Errorf("got contents %q, %q and %q", revisions[1].Content, revisions[3].Content, revisions[4].Content)
+	}
+}
+
+func testDelete(t *testing.T, s Stores) {
+	alice := newUser(t, s, "alice")
+	snippet := insert(t, s, fields("Doomed", models.VisibilityPublic, "go"), alice)
+	insert(t, s, fields("Kept", models.VisibilityPublic, "go"), alice)
+	update(t, s, snippet.ID, fields("Doomed, edited", models.VisibilityPublic, "go"))
+
+	err := s.Snippets.Delete(snippet.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Snippets.Get(snippet.ID)
+	if !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("Get: got %v; want ErrNoRecord", err)
+	}
+	_, err = s.Snippets.GetBySlug(snippet.Slug)
+	if !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("GetBySlug: got %v; want ErrNoRecord", err)
+	}
+
+	if revisions := must(s.Snippets.History(snippet)); len(revisions) != 1 {
+		t.Errorf("got %d versions of a deleted snippet; want only the one passed in", len(revisions))
+	}
+
+	checkTitles(t, "ByTag", must(s.Snippets.ByTag("go", models.PageRequest{Sort: models.SortNewest, Size: 10})).Snippets, "Kept")
+	if tags := must(s.Snippets.Tags(10)); len(tags) != 1 || tags[0].Count != 1 {
+		t.Errorf("got tags %+v; want go once", tags)
+	}
+}
+
+func testListings(t *testing.T, s Stores) {
+	alice := newUser(t, s, "alice")
+	bob := newUser(t, s, "bob")
+
+	insert(t, s, fields("Public one", models.VisibilityPublic, "go"), alice)
+	insert(t, s, fields("Unlisted", models.VisibilityUnlisted, "go"), alice)
+	insert(t, s, fields("Private", models.VisibilityPrivate, "go"), alice)
+	insertExpired(t, s, fields("Expired", models.VisibilityPublic, "go"), alice)
+	insert(t, s, fields("Public two", models.VisibilityPublic, "go", "web"), bob)
+	insert(t, s, fields("Bob's private", models.VisibilityPrivate, "web"), bob)
+
+	checkTitles(t, "Latest", must(s.Snippets.Latest()), "Public two", "Public one")
+
+	page := must(s.Snippets.List(models.PageRequest{Sort: models.SortNewest, Size: 10}))
+	checkTitles(t, "List", page.Snippets, "Public two", "Public one")
+
+	page = must(s.Snippets.ByTag("web", models.PageRequest{Sort: models.SortOldest, Size: 10}))
+	checkTitles(t, "ByTag", page.Snippets, "Public two")
+
+	checkTitles(t, "ByUser", must(s.Snippets.ByUser(alice)), "Private", "Unlisted", "Public one")
+
+	tags := must(s.Snippets.Tags(10))
+	var counts []string
+	for _, tag := range tags {
+		counts = append(counts, fmt.Sprintf("%s:%d", tag.Name, tag.Count))
+	}
+	if !slices.Equal(counts, []string{"go:2", "web:1"}) {
+		t.Errorf("got tag counts %q; want only public snippets counted", counts)
+	}
+
+	for i := range 10 {
+		insert(t, s, fields(fmt.Sprintf("More %d", i), models.VisibilityPublic), bob)
+	}
+	latest := must(s.Snippets.Latest())
+	if len(latest) != 10 || latest[0].Title != "More 9" {
+		t.Errorf("got %d latest snippets starting with %q; want 10 starting with More 9", len(latest), latest[0].Title)
+	}
+}
+
+// testSearch checks which snippets a search finds. Ranking is left to each
+// backend, so results are compared in title order.
+func testSearch(t *testing.T, s Stores) {
+	alice := newUser(t, s, "alice")
+
+	add := func(title, content, visibility string) {
+		f := fields(title, visibility)
+		f.Content = content
+		insert(t, s, f, alice)
+	}
+
+	add("HTTP server", "package main\n\nimport \"net/http\"", models.VisibilityPublic)
+	add("Notes", "an http_client helper", models.VisibilityPublic)
+	add("Gopher", "goroutines and channels", models.VisibilityPublic)
+	add("Algorithms", "sorting in big O", models.VisibilityPublic)
+	add("Über fetch", "fetch with HTTP", models.VisibilityPublic)
+	add("Private http", "http", models.VisibilityPrivate)
+	add("Unlisted http", "http", models.VisibilityUnlisted)
+	insertExpired(t, s, fields("Expired http", models.VisibilityPublic), alice)
+
+	encrypted := fields("Encrypted http", models.VisibilityPublic)
+	encrypted.Content = "v1.AAAAAAAAAAAAAAAA.AAAAAAAAAAAAAAAAAAAAAA"
+	encrypted.Encrypted = true
+	insert(t, s, encrypted, alice)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"http", []string{"HTTP server", "Notes", "Über fetch"}},
+		{"HTT", []string{"HTTP server", "Notes", "Über fetch"}},
+		{"http_client", []string{"Notes"}},
+		{"go", []string{"Gopher"}},
+		{"rithms", nil},
+		{"über", []string{"Über fetch"}},
+		{"server channels", []string{"Gopher", "HTTP server"}},
+		{"private unlisted expired encrypted", nil},
+		{"!!!", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results, err := s.Snippets.Search(tt.query, 1, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, r := range results.Results {
+				got = append(got, r.Snippet.Title)
+			}
+			slices.Sort(got)
+
+			if !slices.Equal(got, tt.want) || results.Total != len(tt.want) {
+				t.Errorf("got %q, total %d; want %q", got, results.Total, tt.want)
+			}
+		})
+	}
+}
+
+func testDeleteExpired(t *testing.T, s Stores) {
+	alice := newUser(t, s, "alice")
+
+	live := insert(t, s, fields("Live", models.VisibilityPublic, "go"), alice)
+
+	var expired []*models.Snippet
+	for i := range 3 {
+		f := fields(fmt.Sprintf("Expired %d", i), models.VisibilityPublic, "go")
+		snippet := insert(t, s, f, alice)
+
+		f.Title += ", edited"
+		f.Expires = time.Now().UTC().Add(-time.Duration(3-i) * time.Minute)
+		update(t, s, snippet.ID, f)
+		expired = append(expired, snippet)
+	}
+
+	for _, want := range []int{2, 1, 0} {
+		n, err := s.Snippets.DeleteExpired(2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != want {
+			t.Errorf("deleted %d; want %d", n, want)
+		}
+	}
+
+	for _, snippet := range expired {
+		if revisions := must(s.Snippets.History(snippet)); len(revisions) != 1 {
+			t.Errorf("%s: revisions survived the deletion", snippet.Title)
+		}
+	}
+
+	if _, err := s.Snippets.Get(live.ID); err != nil {
+		t.Errorf("getting the live snippet: %v", err)
+	}
+	if tags := must(s.Snippets.Tags(10)); len(tags) != 1 || tags[0].Count != 1 {
+		t.Errorf("got tags %+v; want go once", tags)
+	}
+}
+
+func testReveal(t *testing.T, s Stores) {
+	alice := newUser(t, s, "alice")
+
+	f := fields("Twice", models.VisibilityUnlisted, "go")
+	f.MaxViews = 2
+	snippet := insert(t, s, f, alice)
+	unlimited := insert(t, s, fields("Unlimited", models.VisibilityUnlisted), alice)
+	f.Title = "Expired"
+	expired := insertExpired(t, s, f, alice)
+
+	for _, want := range []int{1, 2} {
+		revealed, err := s.Snippets.Reveal(snippet.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if revealed.Views != want || revealed.Title != "Twice" || revealed.Content != snippet.Content ||
+			!slices.Equal(revealed.Tags, []string{"go"}) || revealed.Author != "alice" {
+			t.Errorf("view %d: got %+v", want, revealed)
+		}
+
+		if want == 1 {
+			if got := must(s.Snippets.Get(snippet.ID)); got.Views != 1 || got.RemainingViews() != 1 {
+				t.Errorf("got %d views, %d remaining; want 1 and 1", got.Views, got.RemainingViews())
+			}
+		}
+	}
+
+	_, err := s.Snippets.Get(snippet.ID)
+	if !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("getting a snippet after its last view: got %v; want ErrNoRecord", err)
+	}
+
+	for _, id := range []int{snippet.ID, unlimited.ID, expired.ID, unlimited.ID + 100} {
+		_, err := s.Snippets.Reveal(id)
+		if !errors.Is(err, models.ErrNoRecord) {
+			t.Errorf("revealing snippet %d: got %v; want ErrNoRecord", id, err)
+		}
+	}
+
+	if got := must(s.Snippets.Get(unlimited.ID)); got.Views != 0 {
+		t.Errorf("a snippet without a view limit counted %d views", got.Views)
+	}
+}
+
+// testConcurrentReveal checks that views are counted atomically: however
+// many readers race to reveal a snippet, exactly MaxViews of them see it,
+// and the last of them deletes it.
+func testConcurrentReveal(t *testing.T, s Stores) {
+	const readers, maxViews = 20, 5
+
+	alice := newUser(t, s, "alice")
+
+	f := fields("Secret", models.VisibilityUnlisted, "go")
+	f.MaxViews = maxViews
+	snippet := insert(t, s, f, alice)
+	f.Title = "Secret, edited"
+	update(t, s, snippet.ID, f)
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		views []int
+	)
+	start := make(chan struct{})
+	for range readers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+
+			revealed, err := s.Snippets.Reveal(snippet.ID)
+			if errors.Is(err, models.ErrNoRecord) {
+				return
+			}
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			mu.Lock()
+			views = append(views, revealed.Views)
+			mu.Unlock()
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	slices.Sort(views)
+	if want := []int{1, 2, 3, 4, 5}; !slices.Equal(views, want) {
+		t.Errorf("readers saw views %v; want each of %v once", views, want)
+	}
+
+	_, err := s.Snippets.Get(snippet.ID)
+	if !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("getting the snippet after its last view: got %v; want ErrNoRecord", err)
+	}
+	_, err = s.Snippets.GetBySlug(snippet.Slug)
+	if !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("getting the snippet by slug after its last view: got %v; want ErrNoRecord", err)
+	}
+	if revisions := must(s.Snippets.History(snippet)); len(revisions) != 1 {
+		t.Errorf("the revisions of a burnt snippet survived")
+	}
+}
+
+// testConcurrentAccess reads and changes a snippet at the same time, for the
+// race detector to check.
+func testConcurrentAccess(t *testing.T, s Stores) {
+	alice := newUser(t, s, "alice")
+	snippet := insert(t, s, fields("Busy", models.VisibilityUnlisted, "go"), alice)
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := range 100 {
+				if i%2 == 0 {
+					f := fields(fmt.Sprintf("Busy %d.%d", i, j), models.VisibilityUnlisted, "go")
+					f.MaxViews = 1000
+					if err := s.Snippets.Update(snippet.ID, f); err != nil {
+						t.Error(err)
+					}
+					if _, err := s.Snippets.Reveal(snippet.ID); err != nil && !errors.Is(err, models.ErrNoRecord) {
+						t.Error(err)
+					}
+				} else {
+					if _, err := s.Snippets.Get(snippet.ID); err != nil {
+						t.Error(err)
+					}
+					if _, err := s.Snippets.GetBySlug(snippet.Slug); err != nil {
+						t.Error(err)
+					}
+					if _, err := s.Snippets.ByUser(alice); err != nil {
+						t.Error(err)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// must returns v, panicking if err isn't nil. It keeps the lookups which
+// aren't under test short.
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
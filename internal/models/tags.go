@@ -0,0 +1,83 @@
+package models
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	// MaxTags is the number of tags a snippet can have.
+	MaxTags = 10
+
+	// MaxTagLength is the number of characters a tag can have.
+	MaxTagLength = 30
+)
+
+// TagCount is a tag and the number of non-expired snippets carrying it.
+// Weight ranks the count from 1 to 5 against the other tags in a cloud.
+type TagCount struct {
+	Name   string `json:"name"`
+	Count  int    `json:"count"`
+	Weight int    `json:"-"`
+}
+
+// NormalizeTags lower-cases and trims tags, dropping blanks and duplicates
+// while keeping the original order.
+func NormalizeTags(tags []string) []string {
+	normalized := []string{}
+	seen := map[string]bool{}
+
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		normalized = append(normalized, t)
+	}
+
+	return normalized
+}
+
+// tagCloud weights the tags, which must be ordered by count, most used first,
+// and returns them in alphabetical order.
+func tagCloud(tags []*TagCount) []*TagCount {
+	if len(tags) == 0 {
+		return tags
+	}
+
+	most, least := tags[0].Count, tags[len(tags)-1].Count
+
+	for _, t := range tags {
+		t.Weight = 1
+		if most > least {
+			t.Weight += 4 * (t.Count - least) / (most - least)
+		}
+	}
+
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+
+	return tags
+}
+
+// TagCloud picks the limit most used tags from counts, for backends which
+// can't aggregate in a database.
+func TagCloud(counts map[string]int, limit int) []*TagCount {
+	tags := make([]*TagCount, 0, len(counts))
+	for name, count := range counts {
+		tags = append(tags, &TagCount{Name: name, Count: count})
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Name < tags[j].Name
+	})
+
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+
+	return tagCloud(tags)
+}
@@ -3,28 +3,74 @@ package models
 import (
 	"html/template"
 	"io/fs"
+	"net/url"
 	"path/filepath"
 	"time"
 
+	"github.com/YelzhanWeb/snippetbox/internal/diff"
+	"github.com/YelzhanWeb/snippetbox/internal/highlight"
 	"github.com/YelzhanWeb/snippetbox/ui"
 )
 
 type TemplData struct {
-	CurrentYear     int
-	Snippet         *Snippet
-	Snippets        []*Snippet
-	Form            any
-	Flash           string
-	IsAuthenticated bool
-	CSRFToken       string
+	CurrentYear         int
+	Snippet             *Snippet
+	Snippets            []*Snippet
+	Page                *SnippetPage
+	Tag                 string
+	Tags                []*TagCount
+	Revisions           []*Revision
+	Diff                *RevisionDiff
+	Revealed            bool
+	AllowNeverExpire    bool
+	User                *User
+	Tokens              []*Token
+	NewToken            string
+	Query               string
+	SearchResults       *SearchResults
+	Form                any
+	Flash               string
+	IsAuthenticated     bool
+	AuthenticatedUserID int
+	CSRFToken           string
+}
+
+type RevisionDiff struct {
+	From  *Revision
+	To    *Revision
+	Hunks []diff.Hunk
 }
 
 func humanDate(t time.Time) string {
 	return t.Format("02 Jan 2006 at 15:04")
 }
 
+// expiry formats a snippet's expiry time, which may be Never.
+func expiry(t time.Time) string {
+	if t.Equal(Never) {
+		return "Never"
+	}
+	return humanDate(t)
+}
+
+// highlightCode highlights content as the given language, falling back to
+// escaped plain text if the highlighter fails.
+func highlightCode(language, content string) template.HTML {
+	h, err := highlight.HTML(language, content)
+	if err != nil {
+		return template.HTML(template.HTMLEscapeString(content))
+	}
+	return h
+}
+
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"humanDate":  humanDate,
+	"expiry":     expiry,
+	"sorts":      func() []string { return Sorts },
+	"pathEscape": url.PathEscape,
+	"highlight":  highlightCode,
+	"language":   highlight.Name,
+	"languages":  func() []highlight.Language { return highlight.Languages },
 }
 
 func NewTemplateCache() (map[string]*template.Template, error) {
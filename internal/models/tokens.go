@@ -0,0 +1,153 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+// tokenPrefix makes personal access tokens easy to recognise, for example by
+// secret scanners.
+const tokenPrefix = "sbx_"
+
+// Token is a personal access token. Only a SHA-256 hash of the token is
+// stored; the plaintext is returned once, when the token is created. A zero
+// Expires means the token never expires.
+type Token struct {
+	ID      int       `json:"id"`
+	UserID  int       `json:"-"`
+	Name    string    `json:"name"`
+	Scope   string    `json:"scope"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+// GenerateToken returns a new random plaintext token.
+func GenerateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return tokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the hash under which a plaintext token is stored.
+func HashToken(plaintext string) []byte {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hash[:]
+}
+
+type TokenModel struct {
+	DB *sql.DB
+}
+
+// Insert creates a token for the user which expires after the given number
+// of days, or never if days is 0. It returns the plaintext token.
+func (m *TokenModel) Insert(userID int, name, scope string, days int) (string, error) {
+	plaintext, err := GenerateToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+
+	var expires sql.NullTime
+	if days > 0 {
+		expires = sql.NullTime{Time: now.AddDate(0, 0, days), Valid: true}
+	}
+
+	stmt := `INSERT INTO tokens (user_id, name, hash, scope, created, expires)
+	VALUES (?, ?, ?, ?, ?, ?)`
+
+	_, err = m.DB.Exec(stmt, userID, name, HashToken(plaintext), scope, now, expires)
+	if err != nil {
+		return "", err
+	}
+
+	return plaintext, nil
+}
+
+// Authenticate returns the unexpired token matching the plaintext, or
+// ErrNoRecord if there isn't one.
+func (m *TokenModel) Authenticate(plaintext string) (*Token, error) {
+	stmt := `SELECT id, user_id, name, scope, created, expires FROM tokens
+	WHERE hash = ? AND (expires IS NULL OR expires > ?)`
+
+	t := &Token{}
+	var expires sql.NullTime
+
+	err := m.DB.QueryRow(stmt, HashToken(plaintext), time.Now().UTC()).Scan(&t.ID, &t.UserID, &t.Name, &t.Scope, &t.Created, &expires)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+	t.Expires = expires.Time
+
+	return t, nil
+}
+
+func (m *TokenModel) ForUser(userID int) ([]*Token, error) {
+	stmt := `SELECT id, user_id, name, scope, created, expires FROM tokens
+	WHERE user_id = ? ORDER BY id DESC`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tokens := []*Token{}
+
+	for rows.Next() {
+		t := &Token{}
+		var expires sql.NullTime
+
+		err = rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Scope, &t.Created, &expires)
+		if err != nil {
+			return nil, err
+		}
+		t.Expires = expires.Time
+
+		tokens = append(tokens, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Delete revokes one of the user's tokens. It returns ErrNoRecord if the
+// user has no token with that ID.
+func (m *TokenModel) Delete(id, userID int) error {
+	stmt := `DELETE FROM tokens WHERE id = ? AND user_id = ?`
+
+	result, err := m.DB.Exec(stmt, id, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
@@ -3,42 +3,51 @@ package models
 import (
 	"database/sql"
 	"errors"
-	"strings"
 	"time"
 
-	"github.com/go-sql-driver/mysql"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type User struct {
-	ID             int
-	Name           string
-	Email          string
-	HashedPassword []byte
-	Created        time.Time
+	ID             int       `json:"id"`
+	Name           string    `json:"name"`
+	Email          string    `json:"email"`
+	HashedPassword []byte    `json:"-"`
+	Created        time.Time `json:"created"`
+}
+
+// DefaultBcryptCost is the bcrypt cost used to hash passwords by models
+// whose BcryptCost is zero.
+const DefaultBcryptCost = 12
+
+// HashPassword hashes a user's or a snippet's password with bcrypt at the
+// given cost, or at DefaultBcryptCost if cost is zero.
+func HashPassword(password string, cost int) ([]byte, error) {
+	if cost == 0 {
+		cost = DefaultBcryptCost
+	}
+	return bcrypt.GenerateFromPassword([]byte(password), cost)
 }
 
 type UserModel struct {
-	DB *sql.DB
+	DB         *sql.DB
+	BcryptCost int
 }
 
 func (m *UserModel) Insert(name, email, password string) error {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	hashedPassword, err := HashPassword(password, m.BcryptCost)
 	if err != nil {
 		return err
 	}
 	stmt := `INSERT INTO users (name, email, hashed_password, created)
-	VALUES(?, ?, ?, UTC_TIMESTAMP())`
+	VALUES(?, ?, ?, ?)`
 
-	_, err = m.DB.Exec(stmt, name, email, hashedPassword)
+	_, err = m.DB.Exec(stmt, name, email, hashedPassword, time.Now().UTC())
 	if err != nil {
-		var mySQLError *mysql.MySQLError
-		if errors.As(err, &mySQLError) {
-			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "users_us_email") {
-				return ErrDuplicateEmail
-			}
-			return err
+		if isUniqueViolation(err, "users_us_email", "users.email") {
+			return ErrDuplicateEmail
 		}
+		return err
 	}
 	return nil
 }
@@ -79,3 +88,20 @@ func (m *UserModel) Exists(id int) (bool, error) {
 	err := m.DB.QueryRow(stmt, id).Scan(&exists)
 	return exists, err
 }
+
+func (m *UserModel) Get(id int) (*User, error) {
+	u := &User{}
+
+	stmt := "SELECT id, name, email, created FROM users WHERE id = ?"
+
+	err := m.DB.QueryRow(stmt, id).Scan(&u.ID, &u.Name, &u.Email, &u.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return u, nil
+}
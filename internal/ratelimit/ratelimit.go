@@ -0,0 +1,79 @@
+// Package ratelimit limits how often something may fail, such as a guess at
+// a password, before further attempts are refused for a while.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter allows up to Max failures per key within a fixed Window, which
+// starts at a key's first failure. It is safe for concurrent use.
+type Limiter struct {
+	Max    int
+	Window time.Duration
+
+	mu        sync.Mutex
+	failures  map[string]*window
+	lastSweep time.Time
+}
+
+type window struct {
+	count int
+	reset time.Time
+}
+
+// New returns a Limiter which allows max failures per window.
+func New(max int, window time.Duration) *Limiter {
+	return &Limiter{Max: max, Window: window}
+}
+
+// Reserve records an attempt for key, if one may be made, before its
+// outcome is known, so that concurrent attempts can't all get past the
+// limit. If no attempt may be made it returns false and how long until the
+// key's window ends. A reserved attempt which succeeds should be given back
+// with Refund.
+func (l *Limiter) Reserve(key string) (bool, time.Duration) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.failures == nil {
+		l.failures = make(map[string]*window)
+	}
+
+	// Forget keys whose windows have ended, at most once per window, so that
+	// the map doesn't grow without bound.
+	if now.Sub(l.lastSweep) >= l.Window {
+		for k, w := range l.failures {
+			if !now.Before(w.reset) {
+				delete(l.failures, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	w, ok := l.failures[key]
+	if !ok || !now.Before(w.reset) {
+		w = &window{reset: now.Add(l.Window)}
+		l.failures[key] = w
+	}
+
+	if w.count >= l.Max {
+		return false, w.reset.Sub(now)
+	}
+
+	w.count++
+	return true, 0
+}
+
+// Refund gives back an attempt reserved for key, because it succeeded.
+func (l *Limiter) Refund(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if w, ok := l.failures[key]; ok && w.count > 0 {
+		w.count--
+	}
+}
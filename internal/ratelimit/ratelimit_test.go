@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReserveConcurrent(t *testing.T) {
+	l := New(5, time.Minute)
+
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for range 60 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, _ := l.Reserve("key"); ok {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := allowed.Load(); got != 5 {
+		t.Errorf("got %d attempts allowed; want 5", got)
+	}
+}
+
+func TestReserveRefund(t *testing.T) {
+	l := New(2, time.Minute)
+
+	for i := range 10 {
+		ok, _ := l.Reserve("key")
+		if !ok {
+			t.Fatalf("attempt %d refused although every attempt was refunded", i)
+		}
+		l.Refund("key")
+	}
+
+	l.Reserve("key")
+	l.Reserve("key")
+
+	ok, wait := l.Reserve("key")
+	if ok {
+		t.Fatal("third attempt allowed; want refused")
+	}
+	if wait <= 0 || wait > time.Minute {
+		t.Errorf("got retry after %s; want within the window", wait)
+	}
+
+	if ok, _ := l.Reserve("other"); !ok {
+		t.Error("attempt for another key refused")
+	}
+}
+
+func TestReserveWindowEnds(t *testing.T) {
+	l := New(1, 10*time.Millisecond)
+
+	l.Reserve("key")
+	if ok, _ := l.Reserve("key"); ok {
+		t.Fatal("second attempt allowed within the window")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if ok, _ := l.Reserve("key"); !ok {
+		t.Error("attempt refused after the window ended")
+	}
+}
@@ -0,0 +1,193 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// MaxTerms is the number of distinct terms considered from a query. The rest
+// are ignored.
+const MaxTerms = 10
+
+// Fragment is a piece of highlighted text. Match is true for fragments that
+// match one of the search terms.
+type Fragment struct {
+	Text  string `json:"text"`
+	Match bool   `json:"match"`
+}
+
+// Terms splits a query into distinct lower-case words.
+func Terms(query string) []string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !isWordRune(r)
+	})
+
+	var terms []string
+	seen := map[string]bool{}
+
+	for _, w := range words {
+		if seen[w] {
+			continue
+		}
+		seen[w] = true
+		terms = append(terms, w)
+
+		if len(terms) == MaxTerms {
+			break
+		}
+	}
+
+	return terms
+}
+
+// Matches reports whether a word in text starts with any of the terms, as
+// the full-text indexes of the SQL stores match them.
+func Matches(terms []string, text string) bool {
+	lowered := lower([]rune(text))
+	for _, t := range terms {
+		if index(lowered, []rune(t), 0) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Score ranks a document against the terms. Documents matching more of the
+// terms always rank above those matching fewer; after that, matches in the
+// title count five times as much as matches in the content.
+func Score(terms []string, title, content string) float64 {
+	loweredTitle := lower([]rune(title))
+	loweredContent := lower([]rune(content))
+
+	var matched int
+	var frequency float64
+
+	for _, t := range terms {
+		inTitle := count(loweredTitle, []rune(t))
+		inContent := count(loweredContent, []rune(t))
+
+		if inTitle+inContent > 0 {
+			matched++
+		}
+
+		frequency += 5*float64(inTitle) + float64(inContent)
+	}
+
+	// Cap the frequency so that a long document repeating one term can't
+	// outrank one that matches more of the terms.
+	return float64(matched)*1000 + min(frequency, 999)
+}
+
+// Highlight splits text into fragments, marking the start of every word
+// which starts with one of the terms.
+func Highlight(terms []string, text string) []Fragment {
+	runes := []rune(text)
+	return highlight(terms, runes, lower(runes))
+}
+
+// Excerpt returns roughly width runes of text around the first word
+// matching any term, split into highlighted fragments. If nothing matches, it returns the
+// start of the text.
+func Excerpt(terms []string, text string, width int) []Fragment {
+	runes := []rune(text)
+	lowered := lower(runes)
+
+	first := -1
+	for _, t := range terms {
+		if i := index(lowered, []rune(t), 0); i >= 0 && (first < 0 || i < first) {
+			first = i
+		}
+	}
+
+	start := 0
+	if first > width/4 {
+		start = first - width/4
+	}
+	end := min(start+width, len(runes))
+
+	fragments := highlight(terms, runes[start:end], lowered[start:end])
+
+	if start > 0 && len(fragments) > 0 {
+		fragments = append([]Fragment{{Text: "…"}}, fragments...)
+	}
+	if end < len(runes) {
+		fragments = append(fragments, Fragment{Text: "…"})
+	}
+
+	return fragments
+}
+
+func highlight(terms []string, runes, lowered []rune) []Fragment {
+	// Mark every rune covered by a match, then merge runs into fragments.
+	marked := make([]bool, len(runes))
+	for _, t := range terms {
+		tr := []rune(t)
+		for i := index(lowered, tr, 0); i >= 0; i = index(lowered, tr, i+len(tr)) {
+			for j := i; j < i+len(tr); j++ {
+				marked[j] = true
+			}
+		}
+	}
+
+	var fragments []Fragment
+	for i := 0; i < len(runes); {
+		j := i
+		for j < len(runes) && marked[j] == marked[i] {
+			j++
+		}
+		fragments = append(fragments, Fragment{Text: string(runes[i:j]), Match: marked[i]})
+		i = j
+	}
+
+	return fragments
+}
+
+// lower lower-cases rune by rune, so that indexes into the result are also
+// valid indexes into the original.
+func lower(runes []rune) []rune {
+	lowered := make([]rune, len(runes))
+	for i, r := range runes {
+		lowered[i] = unicode.ToLower(r)
+	}
+	return lowered
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// index returns the index of the first word in s, at or after from, which
+// starts with sub, or -1 if there is none.
+func index(s, sub []rune, from int) int {
+	if len(sub) == 0 {
+		return -1
+	}
+
+	for i := from; i+len(sub) <= len(s); i++ {
+		if i > 0 && isWordRune(s[i-1]) {
+			continue
+		}
+
+		match := true
+		for j := range sub {
+			if s[i+j] != sub[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// count returns the number of words in s which start with sub.
+func count(s, sub []rune) int {
+	n := 0
+	for i := index(s, sub, 0); i >= 0; i = index(s, sub, i+len(sub)) {
+		n++
+	}
+	return n
+}
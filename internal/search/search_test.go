@@ -0,0 +1,153 @@
+package search
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTerms(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", nil},
+		{"  !!! ", nil},
+		{"HTTP Server", []string{"http", "server"}},
+		{"net/http, io.Reader", []string{"net", "http", "io", "reader"}},
+		{"http_client", []string{"http_client"}},
+		{"go Go GO gopher", []string{"go", "gopher"}},
+		{"Über café 42", []string{"über", "café", "42"}},
+		{`"quoted" +required -excluded*`, []string{"quoted", "required", "excluded"}},
+		{"a b c d e f g h i j k l", []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := Terms(tt.query); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		terms []string
+		text  string
+		want  bool
+	}{
+		{[]string{"go"}, "Go is fun", true},
+		{[]string{"go"}, "a gopher", true},
+		{[]string{"go"}, "algorithms", false},
+		{[]string{"http"}, "import \"net/http\"", true},
+		{[]string{"client"}, "http_client", false},
+		{[]string{"über"}, "ÜBER alles", true},
+		{[]string{"x", "fun"}, "Go is fun", true},
+		{nil, "anything", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.terms, " ")+"/"+tt.text, func(t *testing.T) {
+			if got := Matches(tt.terms, tt.text); got != tt.want {
+				t.Errorf("got %t; want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScore(t *testing.T) {
+	terms := []string{"http", "server"}
+
+	tests := []struct {
+		name          string
+		better, worse [2]string // title and content
+	}{
+		{"more terms beat more matches", [2]string{"", "http server"}, [2]string{"http", strings.Repeat("http ", 5000)}},
+		{"title beats content", [2]string{"http", ""}, [2]string{"", "http"}},
+		{"more matches beat fewer", [2]string{"", "http http"}, [2]string{"", "http"}},
+		{"word prefixes only", [2]string{"", "httpd"}, [2]string{"", "xhttp"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			better := Score(terms, tt.better[0], tt.better[1])
+			worse := Score(terms, tt.worse[0], tt.worse[1])
+			if better <= worse {
+				t.Errorf("got %v for %q; want more than %v for %q", better, tt.better, worse, tt.worse)
+			}
+		})
+	}
+
+	if got := Score(terms, "HTTP Server", "server"); got != 2000+5+5+1 {
+		t.Errorf("got %v; want %v", got, 2000+5+5+1)
+	}
+	if got := Score(terms, "nothing", "here"); got != 0 {
+		t.Errorf("got %v for no matches; want 0", got)
+	}
+}
+
+// render shows fragments with their matches in brackets.
+func render(fragments []Fragment) string {
+	var b strings.Builder
+	for _, f := range fragments {
+		if f.Match {
+			b.WriteString("[" + f.Text + "]")
+		} else {
+			b.WriteString(f.Text)
+		}
+	}
+	return b.String()
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		terms []string
+		text  string
+		want  string
+	}{
+		{[]string{"http"}, "HTTP server", "[HTTP] server"},
+		{[]string{"go"}, "Go gophers, not algorithms", "[Go] [go]phers, not algorithms"},
+		{[]string{"http", "server"}, "an http server", "an [http] [server]"},
+		{[]string{"se", "server"}, "server", "[server]"},
+		{[]string{"über"}, "Das ÜBER", "Das [ÜBER]"},
+		{[]string{"x"}, "nothing", "nothing"},
+		{[]string{"x"}, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := render(Highlight(tt.terms, tt.text)); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	long := strings.Repeat("a ", 50) + "needle " + strings.Repeat("b ", 50)
+
+	tests := []struct {
+		name  string
+		terms []string
+		text  string
+		width int
+		want  string
+	}{
+		{"short text", []string{"needle"}, "a needle here", 20, "a [needle] here"},
+		{"no match shows the start", []string{"x"}, "abcdefghij", 4, "abcd…"},
+		{"match near the start", []string{"b"}, "a b c d e f g h", 6, "… [b] c d…"},
+		{"match in the middle", []string{"needle"}, long, 20, "… a a [needle] b b b b …"},
+		{"earliest term wins", []string{"b", "needle"}, long, 20, "… a a [needle] [b] [b] [b] [b] …"},
+		{"mid-word is no match", []string{"eedle"}, "needle", 20, "needle"},
+		{"multibyte text", []string{"ü"}, strings.Repeat("é", 10) + " ü", 4, "… [ü]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := render(Excerpt(tt.terms, tt.text, tt.width)); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
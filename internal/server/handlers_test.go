@@ -0,0 +1,419 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/YelzhanWeb/snippetbox/internal/models"
+	"github.com/justinas/nosurf"
+)
+
+func TestIntegerIDRedirect(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+
+	aliceID := newUser(t, app, "Alice")
+	newUser(t, app, "Bob")
+
+	public := newSnippet(t, app, snippetFields("Public", models.VisibilityPublic), aliceID)
+	unlisted := newSnippet(t, app, snippetFields("Unlisted", models.VisibilityUnlisted), aliceID)
+	private := newSnippet(t, app, snippetFields("Private", models.VisibilityPrivate), aliceID)
+
+	anonymous := newTestClient(t, ts)
+	alice := newTestClient(t, ts)
+	alice.login("Alice")
+	bob := newTestClient(t, ts)
+	bob.login("Bob")
+
+	tests := []struct {
+		name     string
+		client   *testClient
+		method   string
+		path     string
+		want     int
+		location string
+	}{
+		{"view", anonymous, http.MethodGet, "/snippet/view/%d", http.StatusMovedPermanently, "/snippet/view/%s"},
+		{"query kept", anonymous, http.MethodGet, "/snippet/view/%d?tab=raw&x=1", http.StatusMovedPermanently, "/snippet/view/%s?tab=raw&x=1"},
+		{"history", anonymous, http.MethodGet, "/snippet/view/%d/history", http.StatusMovedPermanently, "/snippet/view/%s/history"},
+		{"raw", anonymous, http.MethodGet, "/snippet/raw/%d", http.StatusMovedPermanently, "/snippet/raw/%s"},
+		{"api", anonymous, http.MethodGet, "/api/v1/snippets/%d", http.StatusMovedPermanently, "/api/v1/snippets/%s"},
+		{"post keeps the method", anonymous, http.MethodPost, "/api/v1/snippets/%d/reveal", http.StatusPermanentRedirect, "/api/v1/snippets/%s/reveal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := tt.client.do(tt.method, fmt.Sprintf(tt.path, public.ID), nil, nil)
+			if res.status != tt.want {
+				t.Fatalf("got status %d; want %d", res.status, tt.want)
+			}
+			if want := fmt.Sprintf(tt.location, public.Slug); res.header.Get("Location") != want {
+				t.Errorf("got Location %q; want %q", res.header.Get("Location"), want)
+			}
+		})
+	}
+
+	hidden := []struct {
+		name    string
+		client  *testClient
+		snippet *models.Snippet
+		want    int
+	}{
+		{"unlisted to anonymous", anonymous, unlisted, http.StatusNotFound},
+		{"unlisted to another user", bob, unlisted, http.StatusNotFound},
+		{"unlisted to the owner", alice, unlisted, http.StatusMovedPermanently},
+		{"private to anonymous", anonymous, private, http.StatusNotFound},
+		{"private to another user", bob, private, http.StatusNotFound},
+		{"private to the owner", alice, private, http.StatusMovedPermanently},
+		{"missing", anonymous, &models.Snippet{ID: 999}, http.StatusNotFound},
+	}
+
+	for _, tt := range hidden {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, path := range []string{"/snippet/view/%d", "/api/v1/snippets/%d"} {
+				res := tt.client.get(fmt.Sprintf(path, tt.snippet.ID))
+				if res.status != tt.want {
+					t.Errorf("%s: got status %d; want %d", path, res.status, tt.want)
+				}
+				if tt.want == http.StatusNotFound && res.header.Get("Location") != "" {
+					t.Errorf("%s: redirected to %s", path, res.header.Get("Location"))
+				}
+			}
+		})
+	}
+}
+
+func TestOwnership(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+
+	aliceID := newUser(t, app, "Alice")
+	bobID := newUser(t, app, "Bob")
+
+	aliceToken, err := app.Tokens.Insert(aliceID, "test", models.ScopeWrite, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobToken, err := app.Tokens.Insert(bobID, "test", models.ScopeWrite, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	anonymous := newTestClient(t, ts)
+	alice := newTestClient(t, ts)
+	alice.login("Alice")
+	bob := newTestClient(t, ts)
+	bob.login("Bob")
+
+	edit := url.Values{"title": {"Edited"}, "content": {"Edited content"}}
+	editJSON := `{"title": "Edited", "content": "Edited content"}`
+
+	bearer := func(token string) http.Header {
+		h := http.Header{"Content-Type": {"application/json"}}
+		if token != "" {
+			h.Set("Authorization", "Bearer "+token)
+		}
+		return h
+	}
+
+	tests := []struct {
+		name    string
+		request func(slug string) response
+		want    int
+		// changed is whether the request edits or deletes the snippet.
+		changed bool
+	}{
+		{"edit page by the owner", func(slug string) response { return alice.get("/snippet/edit/" + slug) }, http.StatusOK, false},
+		{"edit page by another user", func(slug string) response { return bob.get("/snippet/edit/" + slug) }, http.StatusForbidden, false},
+		{"edit page anonymously", func(slug string) response { return anonymous.get("/snippet/edit/" + slug) }, http.StatusSeeOther, false},
+
+		{"edit by the owner", func(slug string) response { return alice.postForm("/snippet/edit/"+slug, edit) }, http.StatusSeeOther, true},
+		{"edit by another user", func(slug string) response { return bob.postForm("/snippet/edit/"+slug, edit) }, http.StatusForbidden, false},
+		{"edit anonymously", func(slug string) response { return anonymous.postForm("/snippet/edit/"+slug, edit) }, http.StatusSeeOther, false},
+
+		{"delete by the owner", func(slug string) response { return alice.postForm("/snippet/delete/"+slug, nil) }, http.StatusSeeOther, true},
+		{"delete by another user", func(slug string) response { return bob.postForm("/snippet/delete/"+slug, nil) }, http.StatusForbidden, false},
+		{"delete anonymously", func(slug string) response { return anonymous.postForm("/snippet/delete/"+slug, nil) }, http.StatusSeeOther, false},
+
+		{"API edit by the owner", func(slug string) response {
+			return anonymous.do(http.MethodPut, "/api/v1/snippets/"+slug, strings.NewReader(editJSON), bearer(aliceToken))
+		}, http.StatusOK, true},
+		{"API edit by another user", func(slug string) response {
+			return anonymous.do(http.MethodPut, "/api/v1/snippets/"+slug, strings.NewReader(editJSON), bearer(bobToken))
+		}, http.StatusForbidden, false},
+		{"API edit anonymously", func(slug string) response {
+			return anonymous.do(http.MethodPut, "/api/v1/snippets/"+slug, strings.NewReader(editJSON), bearer(""))
+		}, http.StatusUnauthorized, false},
+
+		{"API delete by the owner", func(slug string) response {
+			return anonymous.do(http.MethodDelete, "/api/v1/snippets/"+slug, nil, bearer(aliceToken))
+		}, http.StatusNoContent, true},
+		{"API delete by another user", func(slug string) response {
+			return anonymous.do(http.MethodDelete, "/api/v1/snippets/"+slug, nil, bearer(bobToken))
+		}, http.StatusForbidden, false},
+		{"API delete anonymously", func(slug string) response {
+			return anonymous.do(http.MethodDelete, "/api/v1/snippets/"+slug, nil, bearer(""))
+		}, http.StatusUnauthorized, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snippet := newSnippet(t, app, snippetFields("Original", models.VisibilityPublic), aliceID)
+
+			res := tt.request(snippet.Slug)
+			if res.status != tt.want {
+				t.Fatalf("got status %d; want %d", res.status, tt.want)
+			}
+			if res.status == http.StatusSeeOther && !tt.changed {
+				if location := res.header.Get("Location"); location != "/user/login" {
+					t.Errorf("redirected to %s; want the login page", location)
+				}
+			}
+
+			got, err := app.Snippets.Get(snippet.ID)
+			switch {
+			case tt.changed && err == nil && got.Title == "Original":
+				t.Error("the snippet wasn't changed")
+			case !tt.changed && err != nil:
+				t.Errorf("the snippet was deleted: %v", err)
+			case !tt.changed && got.Title != "Original":
+				t.Errorf("the snippet was edited to %q", got.Title)
+			}
+		})
+	}
+}
+
+func TestVisibility(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+
+	aliceID := newUser(t, app, "Alice")
+	newUser(t, app, "Bob")
+
+	snippets := map[string]*models.Snippet{}
+	for _, visibility := range models.Visibilities {
+		fields := snippetFields("Zebra "+visibility, visibility)
+		fields.Tags = []string{"zoo"}
+		snippets[visibility] = newSnippet(t, app, fields, aliceID)
+	}
+
+	anonymous := newTestClient(t, ts)
+	alice := newTestClient(t, ts)
+	alice.login("Alice")
+	bob := newTestClient(t, ts)
+	bob.login("Bob")
+
+	viewers := []struct {
+		name   string
+		client *testClient
+		owner  bool
+	}{
+		{"anonymous", anonymous, false},
+		{"another user", bob, false},
+		{"the owner", alice, true},
+	}
+
+	for _, v := range viewers {
+		t.Run("view by "+v.name, func(t *testing.T) {
+			for visibility, snippet := range snippets {
+				want := http.StatusOK
+				if visibility == models.VisibilityPrivate && !v.owner {
+					want = http.StatusNotFound
+				}
+
+				for _, path := range []string{"/snippet/view/", "/snippet/raw/", "/api/v1/snippets/"} {
+					if res := v.client.get(path + snippet.Slug); res.status != want {
+						t.Errorf("%s%s (%s): got status %d; want %d", path, snippet.Slug, visibility, res.status, want)
+					}
+				}
+
+				// Old integer URLs only lead to public snippets, so that
+				// unlisted ones can't be found by counting.
+				want = http.StatusMovedPermanently
+				if visibility != models.VisibilityPublic && !v.owner {
+					want = http.StatusNotFound
+				}
+				if res := v.client.get(fmt.Sprintf("/snippet/view/%d", snippet.ID)); res.status != want {
+					t.Errorf("/snippet/view/%d (%s): got status %d; want %d", snippet.ID, visibility, res.status, want)
+				}
+			}
+		})
+
+		// Listings only ever show public snippets, even to their owner.
+		t.Run("listings for "+v.name, func(t *testing.T) {
+			for _, path := range []string{"/", "/snippets", "/tag/zoo", "/search?q=zebra", "/api/v1/snippets", "/api/v1/search?q=zebra"} {
+				res := v.client.get(path)
+				if res.status != http.StatusOK {
+					t.Errorf("%s: got status %d", path, res.status)
+					continue
+				}
+
+				for visibility, snippet := range snippets {
+					listed := strings.Contains(res.body, snippet.Slug)
+					if listed != (visibility == models.VisibilityPublic) {
+						t.Errorf("%s: listed the %s snippet: %t", path, visibility, listed)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestHistoryOfEncryptedSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+
+	aliceID := newUser(t, app, "Alice")
+
+	plain := newSnippet(t, app, snippetFields("Plain", models.VisibilityPublic), aliceID)
+
+	fields := snippetFields("Encrypted", models.VisibilityPublic)
+	fields.Content = "v1.AAAAAAAAAAAAAAAA.AAAAAAAAAAAAAAAAAAAAAA"
+	fields.Encrypted = true
+	encrypted := newSnippet(t, app, fields, aliceID)
+
+	fields.Content = "v1.BBBBBBBBBBBBBBBB.BBBBBBBBBBBBBBBBBBBBBB"
+	err := app.Snippets.Update(encrypted.ID, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	anonymous := newTestClient(t, ts)
+	alice := newTestClient(t, ts)
+	alice.login("Alice")
+
+	for _, c := range []*testClient{anonymous, alice} {
+		if res := c.get("/snippet/view/" + plain.Slug + "/history"); res.status != http.StatusOK {
+			t.Errorf("plain snippet: got status %d; want %d", res.status, http.StatusOK)
+		}
+
+		for _, query := range []string{"", "?from=1&to=2"} {
+			res := c.get("/snippet/view/" + encrypted.Slug + "/history" + query)
+			if res.status != http.StatusSeeOther {
+				t.Errorf("history%s: got status %d; want %d", query, res.status, http.StatusSeeOther)
+			}
+			if location := res.header.Get("Location"); location != "/snippet/view/"+encrypted.Slug {
+				t.Errorf("history%s: redirected to %q; want the snippet", query, location)
+			}
+			if strings.Contains(res.body, "BBBB") {
+				t.Errorf("history%s shows the ciphertext", query)
+			}
+		}
+	}
+}
+
+func TestAPIWritesNeedToken(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app)
+
+	aliceID := newUser(t, app, "Alice")
+	readToken, err := app.Tokens.Insert(aliceID, "read", models.ScopeRead, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeToken, err := app.Tokens.Insert(aliceID, "write", models.ScopeWrite, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A logged in browser, tricked by another site into sending requests
+	// with its session cookie.
+	alice := newTestClient(t, ts)
+	alice.login("Alice")
+	alice.header.Set("Origin", "https://evil.example.com")
+
+	body := `{"title": "Edited", "content": "Edited content", "expires": "1d"}`
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"session cookie", "", http.StatusForbidden},
+		{"read token", readToken, http.StatusForbidden},
+		{"write token", writeToken, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snippet := newSnippet(t, app, snippetFields("Original", models.VisibilityPublic), aliceID)
+
+			header := http.Header{"Content-Type": {"application/json"}}
+			if tt.token != "" {
+				header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			requests := []struct {
+				method, path string
+				success      int
+			}{
+				{http.MethodPost, "/api/v1/snippets", http.StatusCreated},
+				{http.MethodPut, "/api/v1/snippets/" + snippet.Slug, http.StatusOK},
+				{http.MethodDelete, "/api/v1/snippets/" + snippet.Slug, http.StatusNoContent},
+			}
+
+			for _, req := range requests {
+				want := tt.want
+				if want == 0 {
+					want = req.success
+				}
+
+				res := alice.do(req.method, req.path, strings.NewReader(body), header)
+				if res.status != want {
+					t.Errorf("%s %s: got status %d; want %d", req.method, req.path, res.status, want)
+				}
+			}
+
+			if tt.want != 0 {
+				got, err := app.Snippets.Get(snippet.ID)
+				if err != nil || got.Title != "Original" {
+					t.Errorf("the snippet was changed")
+				}
+			}
+		})
+	}
+
+	// Reading with the session cookie is still allowed.
+	if res := alice.get("/api/v1/user"); res.status != http.StatusOK {
+		t.Errorf("/api/v1/user: got status %d; want %d", res.status, http.StatusOK)
+	}
+}
+
+func TestCSRFCookieSecure(t *testing.T) {
+	for _, secure := range []bool{true, false} {
+		t.Run(fmt.Sprint(secure), func(t *testing.T) {
+			app := newTestApplication(t)
+			app.SessionManager.Cookie.Secure = secure
+
+			res := send(t, Routes(app), http.MethodGet, "/user/login")
+			i := slices.IndexFunc(res.Cookies(), func(c *http.Cookie) bool { return c.Name == nosurf.CookieName })
+			if i < 0 {
+				t.Fatal("no CSRF cookie was set")
+			}
+			if got := res.Cookies()[i].Secure; got != secure {
+				t.Errorf("got a CSRF cookie with Secure %t; want %t", got, secure)
+			}
+		})
+	}
+}
+
+func TestPlainHTTPLogin(t *testing.T) {
+	app := newTestApplication(t)
+	app.SessionManager.Cookie.Secure = false
+	newUser(t, app, "Alice")
+
+	ts := httptest.NewServer(Routes(app))
+	t.Cleanup(ts.Close)
+
+	c := newTestClient(t, ts)
+	c.login("Alice")
+
+	if res := c.get("/account"); res.status != http.StatusOK {
+		t.Errorf("got status %d for the account page; want %d", res.status, http.StatusOK)
+	}
+}
@@ -25,27 +25,88 @@ func Routes(app *ap.Application) http.Handler {
 	router := httprouter.New()
 
 	router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ap.IsAPIRequest(r) {
+			app.NotFoundJSON(w)
+			return
+		}
 		app.NotFound(w)
 	})
 
+	router.MethodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ap.IsAPIRequest(r) {
+			app.ClientErrorJSON(w, http.StatusMethodNotAllowed)
+			return
+		}
+		app.ClientError(w, http.StatusMethodNotAllowed)
+	})
+
+	// handle registers h, recording its pattern for the request metrics.
+	handle := func(method, pattern string, h http.Handler) {
+		router.Handler(method, pattern, ap.WithRoute(pattern, h))
+	}
+
 	fileServer := http.FileServer(http.FS(ui.Files))
-	router.Handler(http.MethodGet, "/static/*filepath", fileServer)
+	handle(http.MethodGet, "/static/*filepath", fileServer)
 
-	dynamic := alice.New(app.SessionManager.LoadAndSave, ap.NoSurf, app.Authenticate)
+	handle(http.MethodGet, "/healthz", handler.Healthz(app))
+	handle(http.MethodGet, "/readyz", handler.Readyz(app))
 
-	router.Handler(http.MethodGet, "/", dynamic.ThenFunc(handler.Home(app)))
-	router.Handler(http.MethodGet, "/snippet/view/:id", dynamic.ThenFunc(handler.SnippetView(app)))
-	router.Handler(http.MethodGet, "/user/signup", dynamic.ThenFunc(handler.UserSignup(app)))
-	router.Handler(http.MethodPost, "/user/signup", dynamic.ThenFunc(handler.UserSignupPost(app)))
-	router.Handler(http.MethodGet, "/user/login", dynamic.ThenFunc(handler.UserLogin(app)))
-	router.Handler(http.MethodPost, "/user/login", dynamic.ThenFunc(handler.UserLoginPost(app)))
+	dynamic := alice.New(app.SessionManager.LoadAndSave, app.NoSurf, app.Authenticate)
+
+	handle(http.MethodGet, "/", dynamic.ThenFunc(handler.Home(app)))
+	handle(http.MethodGet, "/snippets", dynamic.ThenFunc(handler.SnippetList(app)))
+	handle(http.MethodGet, "/tag/:name", dynamic.ThenFunc(handler.TagView(app)))
+	handle(http.MethodGet, "/snippet/view/:slug", dynamic.ThenFunc(handler.SnippetView(app)))
+	handle(http.MethodPost, "/snippet/unlock/:slug", dynamic.ThenFunc(handler.SnippetUnlockPost(app)))
+	handle(http.MethodPost, "/snippet/reveal/:slug", dynamic.ThenFunc(handler.SnippetRevealPost(app)))
+	handle(http.MethodGet, "/snippet/raw/:slug", dynamic.ThenFunc(handler.SnippetRaw(app)))
+	handle(http.MethodGet, "/snippet/download/:slug", dynamic.ThenFunc(handler.SnippetDownload(app)))
+	handle(http.MethodGet, "/snippet/view/:slug/history", dynamic.ThenFunc(handler.SnippetHistory(app)))
+	handle(http.MethodGet, "/search", dynamic.ThenFunc(handler.Search(app)))
+	handle(http.MethodGet, "/user/signup", dynamic.ThenFunc(handler.UserSignup(app)))
+	handle(http.MethodPost, "/user/signup", dynamic.ThenFunc(handler.UserSignupPost(app)))
+	handle(http.MethodGet, "/user/login", dynamic.ThenFunc(handler.UserLogin(app)))
+	handle(http.MethodPost, "/user/login", dynamic.ThenFunc(handler.UserLoginPost(app)))
 
 	protected := dynamic.Append(app.RequireAuthentication)
-	router.Handler(http.MethodGet, "/snippet/create", protected.ThenFunc(handler.SnippetCreate(app)))
-	router.Handler(http.MethodPost, "/snippet/create", protected.ThenFunc(handler.SnippetCreatePost(app)))
-	router.Handler(http.MethodPost, "/user/logout", protected.ThenFunc(handler.UserLogoutPost(app)))
+	handle(http.MethodGet, "/snippet/create", protected.ThenFunc(handler.SnippetCreate(app)))
+	handle(http.MethodPost, "/snippet/create", protected.ThenFunc(handler.SnippetCreatePost(app)))
+	handle(http.MethodGet, "/snippet/edit/:slug", protected.ThenFunc(handler.SnippetEdit(app)))
+	handle(http.MethodPost, "/snippet/edit/:slug", protected.ThenFunc(handler.SnippetEditPost(app)))
+	handle(http.MethodPost, "/snippet/delete/:slug", protected.ThenFunc(handler.SnippetDeletePost(app)))
+	handle(http.MethodPost, "/user/logout", protected.ThenFunc(handler.UserLogoutPost(app)))
+	handle(http.MethodGet, "/account", protected.ThenFunc(handler.Account(app)))
+	handle(http.MethodPost, "/account/tokens", protected.ThenFunc(handler.AccountTokenCreatePost(app)))
+	handle(http.MethodPost, "/account/tokens/:id/delete", protected.ThenFunc(handler.AccountTokenDeletePost(app)))
+
+	api := alice.New(app.SessionManager.LoadAndSave, app.AuthenticateToken, app.Authenticate)
+	handle(http.MethodGet, "/api/v1/snippets", api.ThenFunc(handler.APISnippetList(app)))
+	handle(http.MethodGet, "/api/v1/snippets/:slug", api.ThenFunc(handler.APISnippetGet(app)))
+	handle(http.MethodPost, "/api/v1/snippets/:slug/reveal", api.ThenFunc(handler.APISnippetReveal(app)))
+	handle(http.MethodGet, "/api/v1/search", api.ThenFunc(handler.APISearch(app)))
+	handle(http.MethodGet, "/api/v1/tags", api.ThenFunc(handler.APITagList(app)))
+	handle(http.MethodPost, "/api/detect", api.ThenFunc(handler.APIDetect(app)))
+
+	apiProtected := api.Append(app.RequireAuthenticationJSON)
+	handle(http.MethodGet, "/api/v1/user", apiProtected.ThenFunc(handler.APICurrentUser(app)))
 
-	standard := alice.New(app.RecoverPanic, app.LogRequest, ap.SecureHeaders)
+	apiWrite := apiProtected.Append(app.RequireWriteAccessJSON)
+	handle(http.MethodPost, "/api/v1/snippets", apiWrite.ThenFunc(handler.APISnippetCreate(app)))
+	handle(http.MethodPut, "/api/v1/snippets/:slug", apiWrite.ThenFunc(handler.APISnippetUpdate(app)))
+	handle(http.MethodDelete, "/api/v1/snippets/:slug", apiWrite.ThenFunc(handler.APISnippetDelete(app)))
+
+	standard := alice.New(app.RequestID, app.LogRequest, app.RecordMetrics, app.RecoverPanic, app.SecureHeaders)
 
 	return standard.Then(router)
 }
+
+// AdminRoutes returns the handler for the admin listener, which serves
+// operational endpoints that should not be exposed with the application.
+func AdminRoutes(app *ap.Application) http.Handler {
+	router := httprouter.New()
+
+	router.Handler(http.MethodGet, "/metrics", app.Metrics.Handler())
+	router.Handler(http.MethodGet, "/readyz", handler.ReadyzChecks(app))
+
+	return app.RecoverPanic(router)
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	ap "github.com/YelzhanWeb/snippetbox/internal/app"
+	"github.com/YelzhanWeb/snippetbox/internal/metrics"
+	"github.com/YelzhanWeb/snippetbox/internal/models"
+	"github.com/YelzhanWeb/snippetbox/internal/models/memory"
+	"github.com/YelzhanWeb/snippetbox/internal/ratelimit"
+	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
+	"github.com/go-playground/form"
+)
+
+func newTestApplication(t *testing.T) *ap.Application {
+	t.Helper()
+
+	templateCache, err := models.NewTemplateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionManager := scs.New()
+	sessionManager.Store = memstore.New()
+
+	users := &memory.UserModel{BcryptCost: 4}
+
+	return &ap.Application{
+		Logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Metrics:        metrics.New(nil),
+		Snippets:       &memory.SnippetModel{Users: users, BcryptCost: 4},
+		Users:          users,
+		Tokens:         &memory.TokenModel{},
+		TemplateCache:  templateCache,
+		FormDecoder:    form.NewDecoder(),
+		SessionManager: sessionManager,
+		SnippetUnlocks: ratelimit.New(10, 15*time.Minute),
+		ClientUnlocks:  ratelimit.New(5, time.Minute),
+		CSP:            "default-src 'self'",
+
+		MaxExpiry:        365 * 24 * time.Hour,
+		AllowNeverExpire: true,
+	}
+}
+
+func send(t *testing.T, h http.Handler, method, target string) *http.Response {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(method, target, nil))
+	return rr.Result()
+}
+
+var (
+	helpRX   = regexp.MustCompile(`^# HELP ([a-zA-Z_:][a-zA-Z0-9_:]*) \S.*$`)
+	typeRX   = regexp.MustCompile(`^# TYPE ([a-zA-Z_:][a-zA-Z0-9_:]*) (counter|gauge|histogram)$`)
+	sampleRX = regexp.MustCompile(`^([a-zA-Z_:][a-zA-Z0-9_:]*)(\{(?:[a-zA-Z_][a-zA-Z0-9_]*="(?:[^"\\\n]|\\.)*",?)*\})? (\S+)$`)
+)
+
+// parseExposition checks that body is valid text exposition format, with
+// every sample preceded by the HELP and TYPE lines of its family, and
+// returns the sample values keyed by their names and labels.
+func parseExposition(t *testing.T, body string) map[string]float64 {
+	t.Helper()
+
+	samples := map[string]float64{}
+	types := map[string]string{}
+	var family string
+
+	sc := bufio.NewScanner(strings.NewReader(body))
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+
+		if m := helpRX.FindStringSubmatch(line); m != nil {
+			family = m[1]
+			continue
+		}
+		if m := typeRX.FindStringSubmatch(line); m != nil {
+			if m[1] != family {
+				t.Errorf("line %d: TYPE for %s follows HELP for %s", n, m[1], family)
+			}
+			types[m[1]] = m[2]
+			continue
+		}
+
+		m := sampleRX.FindStringSubmatch(line)
+		if m == nil {
+			t.Errorf("line %d: malformed: %q", n, line)
+			continue
+		}
+
+		name := m[1]
+		if types[family] == "histogram" {
+			name = strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(name, "_bucket"), "_sum"), "_count")
+		}
+		if name != family {
+			t.Errorf("line %d: sample %s outside its family %s", n, m[1], family)
+		}
+
+		value, err := strconv.ParseFloat(m[3], 64)
+		if err != nil {
+			t.Errorf("line %d: bad value %q", n, m[3])
+		}
+		samples[m[1]+m[2]] = value
+	}
+
+	return samples
+}
+
+func TestAdminMetrics(t *testing.T) {
+	app := newTestApplication(t)
+	routes := Routes(app)
+
+	send(t, routes, http.MethodGet, "/snippet/view/abc")
+	send(t, routes, http.MethodGet, "/snippet/view/def")
+	send(t, routes, http.MethodGet, "/user/login")
+	send(t, routes, "FOOBAR", "/x")
+
+	res := send(t, AdminRoutes(app), http.MethodGet, "/metrics")
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d; want %d", res.StatusCode, http.StatusOK)
+	}
+	if ct := res.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("got Content-Type %q; want the text exposition format", ct)
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := parseExposition(t, string(body))
+
+	tests := []struct {
+		sample string
+		want   float64
+	}{
+		{`snippetbox_http_requests_total{method="GET",route="/snippet/view/:slug",status="404"}`, 2},
+		{`snippetbox_http_requests_total{method="GET",route="/user/login",status="200"}`, 1},
+		{`snippetbox_http_requests_total{method="other",route="unmatched",status="404"}`, 1},
+		{`snippetbox_http_request_duration_seconds_count{method="GET",route="/snippet/view/:slug",status="404"}`, 2},
+		{`snippetbox_http_request_duration_seconds_bucket{method="GET",route="/snippet/view/:slug",status="404",le="+Inf"}`, 2},
+		{`snippetbox_template_render_duration_seconds_count{page="login.tmpl.html"}`, 1},
+		{`snippetbox_panics_recovered_total`, 0},
+		{`snippetbox_logins_total{result="success"}`, 0},
+		{`snippetbox_logins_total{result="failure"}`, 0},
+		{`snippetbox_snippets_created_total`, 0},
+	}
+
+	for _, tt := range tests {
+		got, ok := samples[tt.sample]
+		if !ok {
+			t.Errorf("missing %s", tt.sample)
+		} else if got != tt.want {
+			t.Errorf("%s = %v; want %v", tt.sample, got, tt.want)
+		}
+	}
+
+	for sample := range samples {
+		if strings.Contains(sample, "FOOBAR") {
+			t.Errorf("unknown method used as a label: %s", sample)
+		}
+	}
+}
+
+func decodeJSON(t *testing.T, res *http.Response) map[string]any {
+	t.Helper()
+
+	var v map[string]any
+	err := json.NewDecoder(res.Body).Decode(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestReadyz(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name         string
+		shuttingDown bool
+		want         int
+	}{
+		{"ready", false, http.StatusOK},
+		{"shutting down", true, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app.ShuttingDown.Store(tt.shuttingDown)
+
+			res := send(t, Routes(app), http.MethodGet, "/readyz")
+			if res.StatusCode != tt.want {
+				t.Errorf("got status %d; want %d", res.StatusCode, tt.want)
+			}
+			if body := decodeJSON(t, res); len(body) != 1 || body["status"] == nil {
+				t.Errorf("public /readyz shows more than its status: %v", body)
+			}
+
+			res = send(t, AdminRoutes(app), http.MethodGet, "/readyz")
+			if res.StatusCode != tt.want {
+				t.Errorf("got admin status %d; want %d", res.StatusCode, tt.want)
+			}
+			checks, _ := decodeJSON(t, res)["checks"].(map[string]any)
+			shutdown, _ := checks["shutdown"].(map[string]any)
+			if tt.shuttingDown && shutdown["error"] != "the server is shutting down" {
+				t.Errorf("admin /readyz doesn't list the failing check: %v", checks)
+			}
+			if !tt.shuttingDown && shutdown["status"] != "ok" {
+				t.Errorf("admin /readyz doesn't list the passing check: %v", checks)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"html"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	ap "github.com/YelzhanWeb/snippetbox/internal/app"
+	"github.com/YelzhanWeb/snippetbox/internal/models"
+)
+
+// newTestServer serves the application's routes over TLS, as the session
+// and CSRF cookies are only sent to secure origins.
+func newTestServer(t *testing.T, app *ap.Application) *httptest.Server {
+	t.Helper()
+
+	ts := httptest.NewTLSServer(Routes(app))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// testClient is a browser-like client for a test server, with its own
+// cookies. It doesn't follow redirects.
+type testClient struct {
+	t      *testing.T
+	url    string
+	client *http.Client
+
+	// header is sent with every request.
+	header http.Header
+}
+
+func newTestClient(t *testing.T, ts *httptest.Server) *testClient {
+	t.Helper()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ts.Client returns the same client every time, so copy it before
+	// giving it cookies.
+	client := *ts.Client()
+	client.Jar = jar
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &testClient{t: t, url: ts.URL, client: &client, header: http.Header{}}
+}
+
+type response struct {
+	status int
+	header http.Header
+	body   string
+}
+
+func (c *testClient) do(method, path string, body io.Reader, header http.Header) response {
+	c.t.Helper()
+
+	req, err := http.NewRequest(method, c.url+path, body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	for _, h := range []http.Header{c.header, header} {
+		for name, values := range h {
+			req.Header[name] = values
+		}
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	return response{status: res.StatusCode, header: res.Header, body: string(b)}
+}
+
+func (c *testClient) get(path string) response {
+	c.t.Helper()
+	return c.do(http.MethodGet, path, nil, nil)
+}
+
+var csrfTokenRX = regexp.MustCompile(`<input type='hidden' name='csrf_token' value='(.+?)'>`)
+
+// csrfToken returns a CSRF token for the client's cookie.
+func (c *testClient) csrfToken() string {
+	c.t.Helper()
+
+	m := csrfTokenRX.FindStringSubmatch(c.get("/user/login").body)
+	if m == nil {
+		c.t.Fatal("no CSRF token on the login page")
+	}
+	return html.UnescapeString(m[1])
+}
+
+// postForm submits form to path as a same-origin form would, with a CSRF
+// token.
+func (c *testClient) postForm(path string, form url.Values) response {
+	c.t.Helper()
+
+	values := url.Values{"csrf_token": {c.csrfToken()}}
+	for name, v := range form {
+		values[name] = v
+	}
+
+	return c.do(http.MethodPost, path, strings.NewReader(values.Encode()), http.Header{
+		"Content-Type": {"application/x-www-form-urlencoded"},
+		"Origin":       {c.url},
+	})
+}
+
+// login logs the client in as a user made by newUser.
+func (c *testClient) login(name string) {
+	c.t.Helper()
+
+	res := c.postForm("/user/login", url.Values{"email": {email(name)}, "password": {"pa55word"}})
+	if res.status != http.StatusSeeOther {
+		c.t.Fatalf("logging in as %s: got status %d", name, res.status)
+	}
+}
+
+func email(name string) string {
+	return strings.ToLower(name) + "@example.com"
+}
+
+// newUser adds a user with the password "pa55word" and returns their ID.
+func newUser(t *testing.T, app *ap.Application, name string) int {
+	t.Helper()
+
+	err := app.Users.Insert(name, email(name), "pa55word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := app.Users.Authenticate(email(name), "pa55word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// snippetFields returns the fields of a plain text snippet which expires in
+// an hour.
+func snippetFields(title, visibility string) models.SnippetFields {
+	return models.SnippetFields{
+		Title:      title,
+		Content:    title + " content",
+		Language:   "text",
+		Visibility: visibility,
+		Expires:    time.Now().Add(time.Hour),
+	}
+}
+
+// newSnippet inserts a snippet and returns it.
+func newSnippet(t *testing.T, app *ap.Application, fields models.SnippetFields, userID int) *models.Snippet {
+	t.Helper()
+
+	slug, err := app.Snippets.Insert(fields, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snippet, err := app.Snippets.GetBySlug(slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return snippet
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	ap "github.com/YelzhanWeb/snippetbox/internal/app"
+	"github.com/YelzhanWeb/snippetbox/internal/models"
+	"github.com/YelzhanWeb/snippetbox/internal/ratelimit"
+)
+
+const (
+	snippetPassword = "open sesame"
+	secretContent   = "The treasure is under the oak"
+)
+
+// newUnlockTest returns a test application which allows 4 incorrect
+// passwords per snippet and 3 per client, and believes the client addresses
+// sent by test clients in X-Forwarded-For.
+func newUnlockTest(t *testing.T) (*ap.Application, *httptest.Server) {
+	app := newTestApplication(t)
+	app.SnippetUnlocks = ratelimit.New(4, time.Hour)
+	app.ClientUnlocks = ratelimit.New(3, time.Hour)
+	app.TrustedProxies = []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8"), netip.MustParsePrefix("::1/128")}
+
+	return app, newTestServer(t, app)
+}
+
+// newProtectedSnippet adds a password-protected snippet owned by a new user.
+func newProtectedSnippet(t *testing.T, app *ap.Application, owner string) *models.Snippet {
+	fields := snippetFields("Treasure map", models.VisibilityUnlisted)
+	fields.Content = secretContent
+	fields.Password = snippetPassword
+
+	return newSnippet(t, app, fields, newUser(t, app, owner))
+}
+
+// newClientAt returns a test client which appears to connect from ip.
+func newClientAt(t *testing.T, ts *httptest.Server, ip string) *testClient {
+	c := newTestClient(t, ts)
+	c.header.Set("X-Forwarded-For", ip)
+	return c
+}
+
+func (c *testClient) unlock(snippet *models.Snippet, password string) response {
+	c.t.Helper()
+	return c.postForm("/snippet/unlock/"+snippet.Slug, url.Values{"password": {password}})
+}
+
+func (c *testClient) apiGet(snippet *models.Snippet, password string) response {
+	c.t.Helper()
+
+	header := http.Header{}
+	if password != "" {
+		header.Set("X-Snippet-Password", password)
+	}
+	return c.do(http.MethodGet, "/api/v1/snippets/"+snippet.Slug, nil, header)
+}
+
+func checkStatus(t *testing.T, what string, res response, want int) {
+	t.Helper()
+
+	if res.status != want {
+		t.Fatalf("%s: got status %d; want %d", what, res.status, want)
+	}
+	if want == http.StatusTooManyRequests && res.header.Get("Retry-After") == "" {
+		t.Errorf("%s: no Retry-After header", what)
+	}
+}
+
+func TestUnlock(t *testing.T) {
+	app, ts := newUnlockTest(t)
+	snippet := newProtectedSnippet(t, app, "Alice")
+	c := newClientAt(t, ts, "192.0.2.1")
+
+	res := c.get("/snippet/view/" + snippet.Slug)
+	checkStatus(t, "viewing the locked snippet", res, http.StatusOK)
+	if strings.Contains(res.body, secretContent) || !strings.Contains(res.body, "name='password'") {
+		t.Error("the locked snippet's page doesn't ask for its password")
+	}
+
+	res = c.unlock(snippet, "wrong")
+	checkStatus(t, "an incorrect password", res, http.StatusUnprocessableEntity)
+	if !strings.Contains(res.body, "Password is incorrect") {
+		t.Error("the incorrect password isn't reported")
+	}
+
+	res = c.unlock(snippet, snippetPassword)
+	checkStatus(t, "the correct password", res, http.StatusSeeOther)
+	if location := res.header.Get("Location"); location != "/snippet/view/"+snippet.Slug {
+		t.Errorf("redirected to %s; want the snippet", location)
+	}
+
+	res = c.get("/snippet/view/" + snippet.Slug)
+	checkStatus(t, "viewing the unlocked snippet", res, http.StatusOK)
+	if !strings.Contains(res.body, secretContent) {
+		t.Error("the unlocked snippet's content isn't shown")
+	}
+
+	// The session remembers the unlock, for the API too.
+	res = c.unlock(snippet, "wrong")
+	checkStatus(t, "unlocking again", res, http.StatusSeeOther)
+	res = c.apiGet(snippet, "")
+	checkStatus(t, "the API after unlocking", res, http.StatusOK)
+	if !strings.Contains(res.body, secretContent) {
+		t.Error("the API doesn't return the unlocked snippet's content")
+	}
+
+	// Other sessions are still locked out.
+	other := newClientAt(t, ts, "192.0.2.2")
+	res = other.get("/snippet/view/" + snippet.Slug)
+	if strings.Contains(res.body, secretContent) {
+		t.Error("another session sees the unlocked snippet")
+	}
+}
+
+func TestUnlockAPI(t *testing.T) {
+	app, ts := newUnlockTest(t)
+	snippet := newProtectedSnippet(t, app, "Alice")
+	c := newClientAt(t, ts, "192.0.2.1")
+
+	checkStatus(t, "no password", c.apiGet(snippet, ""), http.StatusForbidden)
+	checkStatus(t, "an incorrect password", c.apiGet(snippet, "wrong"), http.StatusForbidden)
+
+	res := c.apiGet(snippet, snippetPassword)
+	checkStatus(t, "the correct password", res, http.StatusOK)
+	if !strings.Contains(res.body, secretContent) {
+		t.Error("the snippet's content isn't returned")
+	}
+
+	// The header unlocks a single request, not the session.
+	checkStatus(t, "no password after unlocking", c.apiGet(snippet, ""), http.StatusForbidden)
+
+	// Owners don't need the password.
+	c.login("Alice")
+	checkStatus(t, "the owner", c.apiGet(snippet, ""), http.StatusOK)
+}
+
+func TestUnlockSnippetLimit(t *testing.T) {
+	app, ts := newUnlockTest(t)
+	snippet := newProtectedSnippet(t, app, "Alice")
+
+	// Each guess comes from a different client, so that only the snippet's
+	// limit of 4 is reached.
+	ips := []string{"192.0.2.1", "192.0.2.2", "192.0.2.3", "192.0.2.4", "192.0.2.5", "192.0.2.6"}
+
+	for _, ip := range ips[:3] {
+		checkStatus(t, "a guess from "+ip, newClientAt(t, ts, ip).unlock(snippet, "wrong"), http.StatusUnprocessableEntity)
+	}
+
+	// The correct password doesn't count as a failure, leaving one more
+	// guess.
+	checkStatus(t, "the correct password", newClientAt(t, ts, ips[3]).unlock(snippet, snippetPassword), http.StatusSeeOther)
+	checkStatus(t, "the fourth guess", newClientAt(t, ts, ips[4]).unlock(snippet, "wrong"), http.StatusUnprocessableEntity)
+
+	c := newClientAt(t, ts, ips[5])
+	checkStatus(t, "the correct password after the limit", c.unlock(snippet, snippetPassword), http.StatusTooManyRequests)
+	checkStatus(t, "the API after the limit", c.apiGet(snippet, snippetPassword), http.StatusTooManyRequests)
+
+	// Other snippets can still be unlocked.
+	another := newProtectedSnippet(t, app, "Bob")
+	checkStatus(t, "another snippet", c.unlock(another, snippetPassword), http.StatusSeeOther)
+}
+
+func TestUnlockClientLimit(t *testing.T) {
+	app, ts := newUnlockTest(t)
+	first := newProtectedSnippet(t, app, "Alice")
+	second := newProtectedSnippet(t, app, "Bob")
+
+	// The client's limit of 3 applies across snippets and to the API.
+	c := newClientAt(t, ts, "192.0.2.1")
+	checkStatus(t, "the first guess", c.unlock(first, "wrong"), http.StatusUnprocessableEntity)
+	checkStatus(t, "the second guess", c.apiGet(first, "wrong"), http.StatusForbidden)
+	checkStatus(t, "the third guess", c.unlock(second, "wrong"), http.StatusUnprocessableEntity)
+	checkStatus(t, "the correct password after the limit", c.unlock(second, snippetPassword), http.StatusTooManyRequests)
+	checkStatus(t, "the API after the limit", c.apiGet(second, snippetPassword), http.StatusTooManyRequests)
+
+	// Other clients aren't limited, and the correct password doesn't count
+	// against them.
+	other := newClientAt(t, ts, "192.0.2.2")
+	checkStatus(t, "another client's first guess", other.unlock(second, "wrong"), http.StatusUnprocessableEntity)
+	checkStatus(t, "another client's second guess", other.unlock(second, "wrong"), http.StatusUnprocessableEntity)
+	checkStatus(t, "another client's correct password", other.apiGet(second, snippetPassword), http.StatusOK)
+	checkStatus(t, "another client's third guess", other.apiGet(second, "wrong"), http.StatusForbidden)
+	checkStatus(t, "another client after its limit", other.apiGet(second, snippetPassword), http.StatusTooManyRequests)
+}
@@ -0,0 +1,60 @@
+// Package sweeper deletes expired snippets in the background. Expired
+// snippets are hidden as soon as they expire, but their rows stay in the
+// database until they are swept.
+package sweeper
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/YelzhanWeb/snippetbox/internal/models"
+)
+
+// Sweeper deletes expired snippets every Interval, at most BatchSize at a
+// time so that no single transaction holds locks for long.
+type Sweeper struct {
+	Snippets  models.SnippetStore
+	Interval  time.Duration
+	BatchSize int
+	Logger    *slog.Logger
+}
+
+// Run sweeps once straight away and then every Interval, until ctx is
+// cancelled. A sweep in progress stops between batches.
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := s.Sweep(ctx)
+		if err != nil {
+			s.Logger.Error("sweeping expired snippets", "error", err)
+		}
+		if n > 0 {
+			s.Logger.Info("deleted expired snippets", "count", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep deletes expired snippets in batches until there are none left or
+// ctx is cancelled, and returns how many it deleted.
+func (s *Sweeper) Sweep(ctx context.Context) (int, error) {
+	total := 0
+
+	for ctx.Err() == nil {
+		n, err := s.Snippets.DeleteExpired(s.BatchSize)
+		total += n
+		if err != nil || n < s.BatchSize {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
@@ -0,0 +1,236 @@
+package sweeper_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/YelzhanWeb/snippetbox/internal/migrate"
+	"github.com/YelzhanWeb/snippetbox/internal/models"
+	"github.com/YelzhanWeb/snippetbox/internal/models/memory"
+	"github.com/YelzhanWeb/snippetbox/internal/sweeper"
+	storage "github.com/YelzhanWeb/snippetbox/pkg/db"
+)
+
+// batchRecorder records the limit and result of every DeleteExpired call,
+// and calls after, if set, after each one.
+type batchRecorder struct {
+	models.SnippetStore
+
+	mu      sync.Mutex
+	limits  []int
+	deleted []int
+	after   func()
+}
+
+func (b *batchRecorder) DeleteExpired(limit int) (int, error) {
+	n, err := b.SnippetStore.DeleteExpired(limit)
+
+	b.mu.Lock()
+	b.limits = append(b.limits, limit)
+	b.deleted = append(b.deleted, n)
+	b.mu.Unlock()
+
+	if b.after != nil {
+		b.after()
+	}
+	return n, err
+}
+
+func (b *batchRecorder) batches() []int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return slices.Clone(b.deleted)
+}
+
+func openSQLiteDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := storage.InitDB("sqlite3", "file:"+filepath.Join(t.TempDir(), "test.db")+"?_synchronous=OFF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m, err := migrate.New(db, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Exec(`INSERT INTO users (name, email, hashed_password, created) VALUES ('Alice', 'alice@example.com', '', ?)`, time.Now().UTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// addSnippets adds count tagged snippets, each with a revision, which have
+// expired if expired is set, and returns their IDs.
+func addSnippets(t *testing.T, store models.SnippetStore, count int, expired bool) []int {
+	t.Helper()
+
+	var ids []int
+	for i := range count {
+		fields := models.SnippetFields{
+			Title:      fmt.Sprintf("Snippet %d", i),
+			Content:    "first version",
+			Language:   "text",
+			Visibility: models.VisibilityPublic,
+			Expires:    time.Now().UTC().Add(time.Hour),
+			Tags:       []string{"go", "sweep"},
+		}
+
+		slug, err := store.Insert(fields, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		snippet, err := store.GetBySlug(slug)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fields.Content = "second version"
+		if expired {
+			fields.Expires = time.Now().UTC().Add(-time.Duration(i+1) * time.Minute)
+		}
+		err = store.Update(snippet.ID, fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids = append(ids, snippet.ID)
+	}
+	return ids
+}
+
+func newSweeper(store models.SnippetStore, batchSize int) *sweeper.Sweeper {
+	return &sweeper.Sweeper{
+		Snippets:  store,
+		Interval:  time.Hour,
+		BatchSize: batchSize,
+		Logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+func TestSweep(t *testing.T) {
+	tests := []struct {
+		expired   int
+		batchSize int
+		want      []int
+	}{
+		{expired: 7, batchSize: 3, want: []int{3, 3, 1}},
+		{expired: 6, batchSize: 3, want: []int{3, 3, 0}},
+		{expired: 2, batchSize: 100, want: []int{2}},
+		{expired: 0, batchSize: 3, want: []int{0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d in batches of %d", tt.expired, tt.batchSize), func(t *testing.T) {
+			db := openSQLiteDB(t)
+			store := &batchRecorder{SnippetStore: &models.SnippetModel{DB: db}}
+
+			live := addSnippets(t, store.SnippetStore, 2, false)
+			addSnippets(t, store.SnippetStore, tt.expired, true)
+
+			n, err := newSweeper(store, tt.batchSize).Sweep(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != tt.expired {
+				t.Errorf("deleted %d snippets; want %d", n, tt.expired)
+			}
+			if got := store.batches(); !slices.Equal(got, tt.want) {
+				t.Errorf("deleted batches of %v; want %v", got, tt.want)
+			}
+			for _, limit := range store.limits {
+				if limit != tt.batchSize {
+					t.Errorf("asked for a batch of %d; want %d", limit, tt.batchSize)
+				}
+			}
+
+			// Only the live snippets and their tags and revisions are left.
+			for _, table := range []struct {
+				name, column string
+				want         int
+			}{
+				{"snippets", "id", 2},
+				{"snippet_tags", "snippet_id", 4},
+				{"snippet_revisions", "snippet_id", 2},
+			} {
+				var total, kept int
+				stmt := fmt.Sprintf(`SELECT COUNT(*), COUNT(CASE WHEN %s IN (?, ?) THEN 1 END) FROM %s`, table.column, table.name)
+				err := db.QueryRow(stmt, live[0], live[1]).Scan(&total, &kept)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if total != table.want || kept != table.want {
+					t.Errorf("%s has %d rows, %d of them for live snippets; want %d", table.name, total, kept, table.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSweepStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := &batchRecorder{SnippetStore: &memory.SnippetModel{Users: &memory.UserModel{}}, after: cancel}
+	addSnippets(t, store.SnippetStore, 7, true)
+
+	n, err := newSweeper(store, 3).Sweep(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 || !slices.Equal(store.batches(), []int{3}) {
+		t.Errorf("deleted %d snippets in batches of %v; want one batch of 3", n, store.batches())
+	}
+}
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	swept := make(chan struct{}, 10)
+	store := &batchRecorder{
+		SnippetStore: &memory.SnippetModel{Users: &memory.UserModel{}},
+		after:        func() { swept <- struct{}{} },
+	}
+	addSnippets(t, store.SnippetStore, 2, true)
+
+	done := make(chan struct{})
+	go func() {
+		newSweeper(store, 100).Run(ctx)
+		close(done)
+	}()
+
+	// The first sweep happens straight away, not after an interval.
+	select {
+	case <-swept:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't sweep straight away")
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't return when its context was cancelled")
+	}
+
+	if got := store.batches(); !slices.Equal(got, []int{2}) {
+		t.Errorf("deleted batches of %v; want one of 2", got)
+	}
+}
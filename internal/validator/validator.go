@@ -8,6 +8,12 @@ import (
 
 var EmailRX = regexp.MustCompile(`^[a-zA-Z0-9.!#$%&'*+/=?^_` + "`" + `{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`)
 
+var TagRX = regexp.MustCompile(`^[a-z0-9][a-z0-9+#._-]*$`)
+
+// EncryptedRX matches content encrypted in the browser by ui/static/js/crypto.js:
+// a version, then the base64url-encoded AES-GCM nonce and ciphertext.
+var EncryptedRX = regexp.MustCompile(`^v1\.[A-Za-z0-9_-]{16}\.[A-Za-z0-9_-]{22,}$`)
+
 type Validator struct {
 	NonFieldErrors []string
 	FieldErrors    map[string]string
@@ -61,3 +67,25 @@ func PermittedValue[T comparable](value T, permittedValues ...T) bool {
 	}
 	return false
 }
+
+func MaxItems[T any](values []T, n int) bool {
+	return len(values) <= n
+}
+
+func AllMaxChars(values []string, n int) bool {
+	for _, value := range values {
+		if !MaxChars(value, n) {
+			return false
+		}
+	}
+	return true
+}
+
+func AllMatch(values []string, rx *regexp.Regexp) bool {
+	for _, value := range values {
+		if !Matches(value, rx) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,10 @@
+package migrations
+
+import "embed"
+
+// Files holds the schema migrations for each SQL storage backend, one
+// directory per dialect. Each migration is a pair of NNNN_name.up.sql and
+// NNNN_name.down.sql files.
+//
+//go:embed "mysql" "sqlite"
+var Files embed.FS
@@ -0,0 +1,13 @@
+CREATE TABLE snippets (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    user_id INTEGER NOT NULL,
+    CONSTRAINT snippets_fk_user_id FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX idx_snippets_created ON snippets (created);
+
+CREATE INDEX idx_snippets_expires ON snippets (expires);
@@ -0,0 +1,8 @@
+CREATE TABLE snippet_revisions (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    CONSTRAINT snippet_revisions_fk_snippet_id FOREIGN KEY (snippet_id) REFERENCES snippets (id)
+);
@@ -0,0 +1,11 @@
+CREATE TABLE tokens (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    hash BINARY(32) NOT NULL,
+    scope VARCHAR(10) NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NULL,
+    CONSTRAINT tokens_fk_user_id FOREIGN KEY (user_id) REFERENCES users (id),
+    CONSTRAINT tokens_uc_hash UNIQUE (hash)
+);
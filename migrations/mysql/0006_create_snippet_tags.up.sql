@@ -0,0 +1,8 @@
+CREATE TABLE snippet_tags (
+    snippet_id INTEGER NOT NULL,
+    tag VARCHAR(30) NOT NULL,
+    PRIMARY KEY (snippet_id, tag),
+    CONSTRAINT snippet_tags_fk_snippet_id FOREIGN KEY (snippet_id) REFERENCES snippets (id)
+);
+
+CREATE INDEX idx_snippet_tags_tag ON snippet_tags (tag);
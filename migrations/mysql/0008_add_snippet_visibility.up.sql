@@ -0,0 +1 @@
+ALTER TABLE snippets ADD COLUMN visibility VARCHAR(10) NOT NULL DEFAULT 'public';
@@ -0,0 +1,3 @@
+ALTER TABLE snippets ADD COLUMN max_views INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE snippets ADD COLUMN views INTEGER NOT NULL DEFAULT 0;
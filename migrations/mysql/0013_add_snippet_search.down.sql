@@ -0,0 +1,3 @@
+DROP INDEX idx_snippets_search ON snippets;
+
+DROP INDEX idx_snippets_search_title ON snippets;
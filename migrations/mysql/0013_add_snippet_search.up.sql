@@ -0,0 +1,3 @@
+CREATE FULLTEXT INDEX idx_snippets_search_title ON snippets (title);
+
+CREATE FULLTEXT INDEX idx_snippets_search ON snippets (title, content);
@@ -0,0 +1,9 @@
+CREATE TABLE users (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    email TEXT NOT NULL COLLATE NOCASE,
+    hashed_password BLOB NOT NULL,
+    created DATETIME NOT NULL
+);
+
+CREATE UNIQUE INDEX users_us_email ON users (email);
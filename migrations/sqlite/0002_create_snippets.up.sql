@@ -0,0 +1,14 @@
+CREATE TABLE snippets (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users (id)
+);
+
+CREATE INDEX idx_snippets_created ON snippets (created);
+
+CREATE INDEX idx_snippets_expires ON snippets (expires);
+
+CREATE INDEX idx_snippets_user_id ON snippets (user_id);
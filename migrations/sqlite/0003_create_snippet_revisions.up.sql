@@ -0,0 +1,9 @@
+CREATE TABLE snippet_revisions (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    snippet_id INTEGER NOT NULL REFERENCES snippets (id),
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_snippet_revisions_snippet_id ON snippet_revisions (snippet_id);
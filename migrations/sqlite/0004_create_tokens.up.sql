@@ -0,0 +1,11 @@
+CREATE TABLE tokens (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users (id),
+    name TEXT NOT NULL,
+    hash BLOB NOT NULL UNIQUE,
+    scope TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME
+);
+
+CREATE INDEX idx_tokens_user_id ON tokens (user_id);
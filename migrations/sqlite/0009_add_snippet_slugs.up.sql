@@ -0,0 +1,7 @@
+ALTER TABLE snippets ADD COLUMN slug TEXT NOT NULL DEFAULT '';
+
+-- Placeholders that can't be valid slugs, so that the unique index can be
+-- built. The migrator replaces them with random slugs before committing.
+UPDATE snippets SET slug = '-' || id;
+
+CREATE UNIQUE INDEX idx_snippets_slug ON snippets (slug);
@@ -0,0 +1,3 @@
+ALTER TABLE snippets DROP COLUMN views;
+
+ALTER TABLE snippets DROP COLUMN max_views;
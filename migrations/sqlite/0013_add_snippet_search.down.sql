@@ -0,0 +1,9 @@
+DROP TRIGGER snippets_search_ai;
+
+DROP TRIGGER snippets_search_bd;
+
+DROP TRIGGER snippets_search_au;
+
+DROP TRIGGER snippets_search_bu;
+
+DROP TABLE snippets_search;
@@ -0,0 +1,21 @@
+CREATE VIRTUAL TABLE snippets_search USING fts4(
+    content="snippets", title, content, tokenize=unicode61 "tokenchars=_"
+);
+
+INSERT INTO snippets_search (snippets_search) VALUES ('rebuild');
+
+CREATE TRIGGER snippets_search_bu BEFORE UPDATE OF title, content ON snippets BEGIN
+    DELETE FROM snippets_search WHERE docid = old.id;
+END;
+
+CREATE TRIGGER snippets_search_au AFTER UPDATE OF title, content ON snippets BEGIN
+    INSERT INTO snippets_search (docid, title, content) VALUES (new.id, new.title, new.content);
+END;
+
+CREATE TRIGGER snippets_search_bd BEFORE DELETE ON snippets BEGIN
+    DELETE FROM snippets_search WHERE docid = old.id;
+END;
+
+CREATE TRIGGER snippets_search_ai AFTER INSERT ON snippets BEGIN
+    INSERT INTO snippets_search (docid, title, content) VALUES (new.id, new.title, new.content);
+END;
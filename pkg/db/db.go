@@ -4,14 +4,28 @@ import (
 	"database/sql"
 
 	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/mattn/go-sqlite3"
 )
 
-func InitDB(dsn string) (*sql.DB, error) {
-	db, err := sql.Open("mysql", dsn)
+// InitDB opens and pings a database using the named driver, either "mysql"
+// or "sqlite3".
+func InitDB(driver, dsn string) (*sql.DB, error) {
+	name := driver
+	if driver == "sqlite3" {
+		name = sqliteDriver
+	}
+
+	db, err := sql.Open(name, dsn)
 	if err != nil {
 		return nil, err
 	}
 
+	// SQLite only allows one writer at a time, so serialise access through a
+	// single connection rather than fail with "database is locked".
+	if driver == "sqlite3" {
+		db.SetMaxOpenConns(1)
+	}
+
 	if err = db.Ping(); err != nil {
 		return nil, err
 	}
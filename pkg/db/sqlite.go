@@ -0,0 +1,83 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"math"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteDriver is the go-sqlite3 driver with the functions used by the
+// snippet queries registered on every connection.
+const sqliteDriver = "sqlite3_snippetbox"
+
+func init() {
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("bm25", BM25, true)
+		},
+	})
+}
+
+// BM25 ranks a row of a full-text search by the Okapi BM25 formula, higher
+// being better. info is the result of matchinfo(table, 'pcnalx') for the
+// row, and weights optionally scale the score of each column in turn.
+//
+// SQLite's FTS5 extension has this built in, but go-sqlite3 only includes
+// FTS5 when built with the sqlite_fts5 tag, so searches use FTS4 and rank
+// with this function instead.
+func BM25(info []byte, weights ...float64) (float64, error) {
+	const k1, b = 1.2, 0.75
+
+	if len(info)%4 != 0 {
+		return 0, errors.New("bm25: matchinfo must be given the 'pcnalx' format")
+	}
+
+	v := make([]uint32, len(info)/4)
+	for i := range v {
+		v[i] = binary.NativeEndian.Uint32(info[4*i:])
+	}
+
+	if len(v) < 3 {
+		return 0, errors.New("bm25: matchinfo must be given the 'pcnalx' format")
+	}
+	phrases, cols, rows := int(v[0]), int(v[1]), float64(v[2])
+	avgLen := v[3 : 3+cols]
+	colLen := v[3+cols : 3+2*cols]
+	hits := v[3+2*cols:]
+
+	if len(hits) != 3*phrases*cols {
+		return 0, errors.New("bm25: matchinfo must be given the 'pcnalx' format")
+	}
+
+	score := 0.0
+	for p := range phrases {
+		for c := range cols {
+			weight := 1.0
+			if c < len(weights) {
+				weight = weights[c]
+			}
+
+			x := hits[3*(p*cols+c):]
+			tf, docs := float64(x[0]), float64(x[2])
+			if tf == 0 || weight == 0 {
+				continue
+			}
+
+			// Terms in more than half the rows would get a negative IDF,
+			// so floor it as FTS5 does.
+			idf := max(math.Log((rows-docs+0.5)/(docs+0.5)), 1e-6)
+
+			norm := 1.0
+			if avgLen[c] > 0 {
+				norm = 1 - b + b*float64(colLen[c])/float64(avgLen[c])
+			}
+
+			score += weight * idf * tf * (k1 + 1) / (tf + k1*norm)
+		}
+	}
+
+	return score, nil
+}
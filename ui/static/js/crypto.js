@@ -0,0 +1,131 @@
+// End-to-end encryption of snippets. Content is encrypted with AES-GCM
+// before it is submitted, and the key is kept in the URL fragment, which
+// browsers never send to the server. Encrypted content is stored as
+// "v1.<nonce>.<ciphertext>", both base64url-encoded.
+
+function toBase64URL(bytes) {
+	var binary = "";
+	for (var i = 0; i < bytes.length; i++) {
+		binary += String.fromCharCode(bytes[i]);
+	}
+	return btoa(binary).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+
+function fromBase64URL(text) {
+	var binary = atob(text.replace(/-/g, "+").replace(/_/g, "/"));
+	var bytes = new Uint8Array(binary.length);
+	for (var i = 0; i < binary.length; i++) {
+		bytes[i] = binary.charCodeAt(i);
+	}
+	return bytes;
+}
+
+function fragmentKey() {
+	return window.location.hash.slice(1);
+}
+
+function importKey(key) {
+	return crypto.subtle.importKey("raw", fromBase64URL(key), "AES-GCM", false, ["encrypt", "decrypt"]);
+}
+
+function newKey() {
+	return crypto.subtle.generateKey({name: "AES-GCM", length: 256}, true, ["encrypt", "decrypt"]).then(function(key) {
+		return crypto.subtle.exportKey("raw", key);
+	}).then(function(raw) {
+		return toBase64URL(new Uint8Array(raw));
+	});
+}
+
+function encryptText(key, text) {
+	var nonce = crypto.getRandomValues(new Uint8Array(12));
+	return importKey(key).then(function(k) {
+		return crypto.subtle.encrypt({name: "AES-GCM", iv: nonce}, k, new TextEncoder().encode(text));
+	}).then(function(ciphertext) {
+		return "v1." + toBase64URL(nonce) + "." + toBase64URL(new Uint8Array(ciphertext));
+	});
+}
+
+function decryptText(key, blob) {
+	var parts = blob.split(".");
+	if (parts.length != 3 || parts[0] != "v1") {
+		return Promise.reject(new Error("unknown encryption format"));
+	}
+	return importKey(key).then(function(k) {
+		return crypto.subtle.decrypt({name: "AES-GCM", iv: fromBase64URL(parts[1])}, k, fromBase64URL(parts[2]));
+	}).then(function(plaintext) {
+		return new TextDecoder().decode(plaintext);
+	});
+}
+
+// Carry the key along to the pages reached by following links and
+// submitting forms from this one. Redirects keep the fragment, so it also
+// survives the redirect after a form is submitted.
+var keepKey = document.querySelectorAll("[data-keep-key]");
+for (var i = 0; i < keepKey.length; i++) {
+	var el = keepKey[i];
+	if (window.location.hash) {
+		if (el.tagName == "FORM") {
+			el.action = el.getAttribute("action") + window.location.hash;
+		} else {
+			el.href = el.getAttribute("href") + window.location.hash;
+		}
+	}
+}
+
+var encrypted = document.querySelectorAll("code[data-ciphertext]");
+for (var i = 0; i < encrypted.length; i++) {
+	(function(code) {
+		if (!fragmentKey()) {
+			code.textContent = "This snippet is encrypted and the link you followed doesn't include its key.";
+			return;
+		}
+		decryptText(fragmentKey(), code.getAttribute("data-ciphertext")).then(function(text) {
+			code.textContent = text;
+		}, function() {
+			code.textContent = "This snippet couldn't be decrypted. The key in the link may be wrong.";
+		});
+	})(encrypted[i]);
+}
+
+var encryptBox = document.querySelector("form input[name='encrypted']");
+if (encryptBox) {
+	var form = encryptBox.form;
+	var content = form.querySelector("textarea[name='content']");
+
+	// Forms for encrypted snippets are filled in with ciphertext when editing,
+	// or when a submission failed validation. Decrypt it for editing. Without
+	// the key, the ciphertext is left alone and submitted unchanged.
+	var undecrypted = "";
+	if (encryptBox.checked && /^v1\./.test(content.value)) {
+		undecrypted = content.value;
+		if (fragmentKey()) {
+			decryptText(fragmentKey(), content.value).then(function(text) {
+				content.value = text;
+				undecrypted = "";
+			});
+		}
+	}
+
+	form.addEventListener("submit", function(event) {
+		if (!encryptBox.checked || (undecrypted && content.value == undecrypted)) {
+			return;
+		}
+		event.preventDefault();
+
+		var key = fragmentKey();
+		var ready = key ? Promise.resolve(key) : newKey();
+		ready.then(function(k) {
+			key = k;
+			return encryptText(key, content.value);
+		}).then(function(blob) {
+			var hidden = document.createElement("input");
+			hidden.type = "hidden";
+			hidden.name = "content";
+			hidden.value = blob;
+			content.removeAttribute("name");
+			form.appendChild(hidden);
+			form.action = form.getAttribute("action").split("#")[0] + "#" + key;
+			form.submit();
+		});
+	});
+}